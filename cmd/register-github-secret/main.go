@@ -1,12 +1,26 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/aereal/register-github-secret/internal/keycache"
 	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/aereal/register-github-secret/internal/metrics"
+	"github.com/aereal/register-github-secret/internal/tracing"
 	"github.com/aereal/register-github-secret/internal/usecases"
 	"github.com/google/go-github/v69/github"
 )
@@ -14,13 +28,283 @@ import (
 func main() { os.Exit(run()) }
 
 func run() int {
-	log.Setup()
-	ctx := context.Background()
-	client := github.NewClient(nil).WithAuthToken(os.Getenv("GITHUB_TOKEN"))
-	uc := usecases.NewRegisterRepositorySecret(client.Actions)
-	if err := cli.NewApp(uc).Run(ctx, os.Args); err != nil {
+	secrets := new(log.Secrets)
+	log.Setup(secrets)
+	closeLog := func() error { return nil }
+	defer func() { _ = closeLog() }()
+	setupLogger := func(cfg log.Config) error {
+		closeFile, err := log.Configure(cfg, secrets)
+		if err != nil {
+			return err
+		}
+		closeLog = closeFile
+		return nil
+	}
+	shutdownTracer := func(context.Context) error { return nil }
+	defer func() {
+		// the spans are flushed even if the run was interrupted
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracer(ctx); err != nil {
+			slog.WarnContext(ctx, "failed to flush the spans", log.AttrError(err))
+		}
+	}()
+	setupTracer := func(ctx context.Context, cfg tracing.Config) error {
+		shutdown, err := tracing.Setup(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		shutdownTracer = shutdown
+		return nil
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runMetrics := metrics.New()
+	newProvider := func(ctx context.Context, cfg cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return newUsecaseProvider(ctx, cfg, secrets, runMetrics)
+	}
+	app := cli.NewApp(newProvider, os.Stdout, cli.WithSecretRegistry(secrets), cli.WithLoggerSetup(setupLogger), cli.WithTracerSetup(setupTracer), cli.WithRunMetrics(runMetrics))
+	if err := app.Run(ctx, os.Args); err != nil {
 		slog.ErrorContext(ctx, "Run failed", log.AttrError(err))
-		return 1
+		return cli.ExitCode(err)
+	}
+	return cli.ExitOK
+}
+
+// newUsecaseProvider registers the tokens it resolves to secrets, which may be nil, and counts the API calls into runMetrics.
+func newUsecaseProvider(ctx context.Context, cfg cli.UsecaseConfig, secrets *log.Secrets, runMetrics usecases.Metrics) (cli.UsecaseProvider, error) {
+	endpoint, err := ghclient.ParseEndpoint(cfg.GitHubURL)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := ghclient.NewHTTPTransport(ghclient.HTTPConfig{
+		CABundleFile:   cfg.CABundleFile,
+		ClientCertFile: cfg.ClientCertFile,
+		ClientKeyFile:  cfg.ClientKeyFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the requests are traced when the logs are written at the debug level
+	transport = &ghclient.TracingTransport{Base: transport}
+	clientCfg := ghclient.Config{AppID: cfg.AppID, Endpoint: endpoint, Transport: transport, UserAgent: cfg.UserAgent}
+	policy := usecases.DefaultRetryPolicy
+	policy.MaxAttempts = cfg.MaxAttempts
+	p := &usecaseProvider{
+		keyCacheTTL:     cfg.KeyCacheTTL,
+		keyCacheDir:     cfg.KeyCacheDir,
+		secrets:         secrets,
+		defaultEndpoint: endpoint,
+		clients:         map[string]*ghclient.Provider{},
+		opts: []usecases.Option{
+			usecases.WithRetryPolicy(policy),
+			usecases.WithRequestTimeout(cfg.RequestTimeout),
+			usecases.WithMetrics(runMetrics),
+		},
+	}
+	if p.keyCacheTTL > 0 && p.keyCacheDir == "" {
+		cacheDir, cacheErr := os.UserCacheDir()
+		if cacheErr != nil {
+			// the cache is optional, so the environments without a home such as cron go on without it
+			slog.WarnContext(ctx, "public key cache disabled as the cache directory is unknown", log.AttrError(cacheErr))
+			p.keyCacheTTL = 0
+		} else {
+			p.keyCacheDir = filepath.Join(cacheDir, "register-github-secret", "public-keys")
+		}
+	}
+	if cfg.TokenMapFile != "" {
+		// tokens are resolved per owner as the targets are visited
+		p.tokenMap, err = ghclient.LoadTokenMap(cfg.TokenMapFile, endpoint.Host)
+		if err != nil {
+			return nil, err
+		}
+		p.clientCfg = clientCfg
+		return p, nil
+	}
+	if cfg.AppID == 0 {
+		token, err := ghclient.ResolveToken(ctx, ghclient.DefaultCredentialSources(cfg.TokenFile, endpoint.Host)...)
+		if errors.Is(err, ghclient.ErrNoCredential) {
+			return nil, cli.ErrMissingToken
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets.Add(token)
+		clientCfg.Token = token
+	}
+	if cfg.AppPrivateKeyFile != "" {
+		pemBytes, err := os.ReadFile(cfg.AppPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read GitHub App private key: %w", err)
+		}
+		key, err := ghclient.ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		clientCfg.AppPrivateKey = key
+	}
+	p.clientCfg = clientCfg
+	p.clients[endpoint.Host] = ghclient.NewProvider(clientCfg)
+	return p, nil
+}
+
+type usecaseProvider struct {
+	clients map[string]*ghclient.Provider
+	// tokenMap is nil unless each owner has its own token.
+	tokenMap *ghclient.TokenMap
+	secrets  *log.Secrets
+	// keyCacheDir has a directory per host; the cache is disabled when keyCacheTTL is zero.
+	keyCacheDir     string
+	opts            []usecases.Option
+	defaultEndpoint ghclient.Endpoint
+	clientCfg       ghclient.Config
+	keyCacheTTL     time.Duration
+	mux             sync.Mutex
+}
+
+var _ cli.UsecaseProvider = (*usecaseProvider)(nil)
+
+func (p *usecaseProvider) RegisterRepositorySecretUsecase(ctx context.Context, account cli.Account) (cli.RegisterRepositorySecretUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	opts := p.opts
+	if p.keyCacheTTL > 0 {
+		dir := filepath.Join(p.keyCacheDir, p.host(account))
+		opts = append(slices.Clip(opts), usecases.WithPublicKeyCache(keycache.New(dir, p.keyCacheTTL)))
+	}
+	return usecases.NewRegisterRepositorySecret(usecases.NewTracedActionsService(client.Actions), opts...), nil
+}
+
+func (p *usecaseProvider) CheckRepositoryAccessUsecase(ctx context.Context, account cli.Account) (cli.CheckRepositoryAccessUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return usecases.NewCheckRepositoryAccess(client.Repositories, usecases.NewTracedActionsService(client.Actions), p.opts...), nil
+}
+
+func (p *usecaseProvider) CheckSecretQuotaUsecase(ctx context.Context, account cli.Account) (cli.CheckSecretQuotaUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return usecases.NewCheckSecretQuota(usecases.NewTracedActionsService(client.Actions), p.opts...), nil
+}
+
+func (p *usecaseProvider) GetRepositoryPublicKeyUsecase(ctx context.Context, account cli.Account) (cli.GetRepositoryPublicKeyUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+	return usecases.NewGetRepositoryPublicKey(usecases.NewTracedActionsService(client.Actions), p.opts...), nil
+}
+
+func (p *usecaseProvider) UploadSealedRepositorySecretUsecase(ctx context.Context, account cli.Account) (cli.UploadSealedRepositorySecretUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return usecases.NewUploadSealedRepositorySecret(usecases.NewTracedActionsService(client.Actions), p.opts...), nil
+}
+
+func (p *usecaseProvider) ListOrganizationRepositoriesUsecase(ctx context.Context, account cli.Account) (cli.ListOrganizationRepositoriesUsecase, error) {
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return usecases.NewListOrganizationRepositories(client.Repositories, p.opts...), nil
+}
+
+func (p *usecaseProvider) IdentifyActorUsecase(ctx context.Context, account cli.Account) (cli.IdentifyActorUsecase, error) {
+	if p.tokenMap == nil && p.clientCfg.AppID != 0 {
+		// the installation token belongs to no user
+		return appActor(p.clientCfg.AppID), nil
+	}
+	client, err := p.client(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return usecases.NewIdentifyActor(client.Users, p.opts...), nil
+}
+
+func (p *usecaseProvider) client(ctx context.Context, account cli.Account) (*github.Client, error) {
+	host := p.host(account)
+	// clients are shared by the owners on a host unless the tokens are mapped per owner
+	key := host
+	if p.tokenMap != nil {
+		key = host + "/" + strings.ToLower(account.Owner)
+	}
+	p.mux.Lock()
+	clients, ok := p.clients[key]
+	if !ok {
+		var err error
+		clients, err = p.newClients(ctx, host, account.Owner)
+		if err != nil {
+			p.mux.Unlock()
+			return nil, err
+		}
+		p.clients[key] = clients
+	}
+	p.mux.Unlock()
+	return clients.Client(ctx, account.Owner)
+}
+
+func (p *usecaseProvider) host(account cli.Account) string {
+	return cmp.Or(account.Host, p.defaultEndpoint.Host)
+}
+
+// newClients builds the client provider for the owner on the host other than the ones built upfront.
+// The token file and the environment variables belong to the default host, so the other hosts take the token only from the host-aware sources.
+func (p *usecaseProvider) newClients(ctx context.Context, host, owner string) (*ghclient.Provider, error) {
+	endpoint := p.defaultEndpoint
+	if host != endpoint.Host {
+		var err error
+		endpoint, err = ghclient.ParseEndpoint("https://" + host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var sources []ghclient.CredentialSource
+	switch {
+	case p.tokenMap != nil:
+		src, err := p.tokenMap.Source(host, owner)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", cli.ErrMissingToken, err)
+		}
+		sources = []ghclient.CredentialSource{src}
+	case p.clientCfg.AppID != 0:
+		return nil, &unsupportedAppHostError{host: host}
+	default:
+		sources = []ghclient.CredentialSource{
+			&ghclient.GHCLIHostsCredential{Path: ghclient.DefaultGHHostsPath(), Host: endpoint.Host},
+			&ghclient.GitCredentialHelper{Host: endpoint.Host},
+		}
+	}
+	token, err := ghclient.ResolveToken(ctx, sources...)
+	if errors.Is(err, ghclient.ErrNoCredential) {
+		return nil, fmt.Errorf("%s/%s: %w", host, owner, cli.ErrMissingToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.secrets.Add(token)
+	cfg := p.clientCfg
+	cfg.Endpoint = endpoint
+	cfg.Token = token
+	return ghclient.NewProvider(cfg), nil
+}
+
+// appActor identifies the writes made as the installation of the GitHub App.
+type appActor int64
+
+func (a appActor) DoIdentifyActor(context.Context) (string, error) {
+	return fmt.Sprintf("app/%d", int64(a)), nil
+}
+
+type unsupportedAppHostError struct{ host string }
+
+func (e *unsupportedAppHostError) Error() string {
+	return fmt.Sprintf("GitHub App authentication is only available on the default host, not on %s", e.host)
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/audit"
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/ghfake"
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/aereal/register-github-secret/internal/metrics"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// runApp runs the command given by args against the fake server and returns what it wrote to stdout.
+// The flags to talk to the server are put after the subcommand names at the head of args.
+func runApp(t *testing.T, srv *ghfake.Server, args ...string) (string, error) {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	commands := 0
+	for commands < len(args) && !strings.HasPrefix(args[commands], "-") {
+		commands++
+	}
+	argv := append([]string{"register-github-secret"}, args[:commands]...)
+	argv = append(argv,
+		"-github-url", srv.URL(),
+		"-token-file", tokenFile,
+		"-key-cache-dir", t.TempDir(),
+		"-max-attempts", "1",
+	)
+	argv = append(argv, args[commands:]...)
+	out := new(bytes.Buffer)
+	secrets := new(log.Secrets)
+	runMetrics := metrics.New()
+	newProvider := func(ctx context.Context, cfg cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return newUsecaseProvider(ctx, cfg, secrets, runMetrics)
+	}
+	err := cli.NewApp(newProvider, out, cli.WithSecretRegistry(secrets), cli.WithRunMetrics(runMetrics)).Run(t.Context(), argv)
+	return out.String(), err
+}
+
+func newFakeServer(t *testing.T) *ghfake.Server {
+	t.Helper()
+	srv := ghfake.NewServer(t, ghfake.WithToken("test-token"), ghfake.WithPerPage(1))
+	srv.AddOrganization("myorg")
+	srv.AddRepository("myorg", "repo1")
+	srv.AddRepository("myorg", "repo2")
+	srv.AddArchivedRepository("myorg", "old")
+	return srv
+}
+
+func TestRegister(t *testing.T) {
+	srv := newFakeServer(t)
+	for _, value := range []string{"first", "second"} {
+		if _, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", value, "-repos", "myorg/repo1", "-repos", "myorg/repo2"); err != nil {
+			t.Fatal(err)
+		}
+		for _, repo := range []string{"repo1", "repo2"} {
+			if got, _ := srv.RepoSecret("myorg", repo, "MY_SECRET"); got != value {
+				t.Errorf("secret of %s = %q; want %q", repo, got, value)
+			}
+		}
+	}
+}
+
+func TestRegister_qualifiedWithPort(t *testing.T) {
+	srv := newFakeServer(t)
+	// the host of the fake server has the port, as the one of a GitHub Enterprise Server on a non-default port does
+	if _, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", "value", "-repos", serverHost(t, srv)+"/myorg/repo1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.RepoSecret("myorg", "repo1", "MY_SECRET"); got != "value" {
+		t.Errorf("secret = %q; want %q", got, "value")
+	}
+}
+
+func serverHost(t *testing.T, srv *ghfake.Server) string {
+	t.Helper()
+	u, err := url.Parse(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestRegister_noCacheDir(t *testing.T) {
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	srv := newFakeServer(t)
+	if _, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", "value", "-repos", "myorg/repo1", "-key-cache-dir", ""); err != nil {
+		t.Fatalf("the run must go on without the public key cache: %v", err)
+	}
+	if got, _ := srv.RepoSecret("myorg", "repo1", "MY_SECRET"); got != "value" {
+		t.Errorf("secret = %q; want %q", got, "value")
+	}
+}
+
+func TestRegister_preflightFailure(t *testing.T) {
+	srv := newFakeServer(t)
+	out, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", "v", "-repos", "myorg/repo1", "-repos", "myorg/unknown", "-output", "json")
+	if got := cli.ExitCode(err); got != cli.ExitAuth {
+		t.Errorf("exit code = %d (%v); want %d", got, err, cli.ExitAuth)
+	}
+	var report cli.RunReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatal(err)
+	}
+	gotActions := map[string]string{}
+	for _, tr := range report.Targets {
+		gotActions[tr.Repo] = tr.Action
+	}
+	if diff := cmp.Diff(map[string]string{"repo1": "skipped", "unknown": "skipped"}, gotActions); diff != "" {
+		t.Errorf("actions (-want, +got):\n%s", diff)
+	}
+	if _, ok := srv.RepoSecret("myorg", "repo1", "MY_SECRET"); ok {
+		t.Error("nothing should be written when the preflight fails")
+	}
+}
+
+func TestRegister_stalledWrite(t *testing.T) {
+	srv := ghfake.NewServer(t, ghfake.WithToken("test-token"), ghfake.WithStalledSecretWrites())
+	srv.AddRepository("myorg", "repo1")
+	done := make(chan error, 1)
+	go func() {
+		_, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", "v", "-repos", "myorg/repo1", "-timeout", "500ms", "-request-timeout", "0")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want the deadline exceeded but got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the write must give up at the deadline of the run")
+	}
+}
+
+func TestRegister_metricsFile(t *testing.T) {
+	srv := newFakeServer(t)
+	path := filepath.Join(t.TempDir(), "register_github_secret.prom")
+	if _, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", "v", "-repos", "myorg/repo1", "-repos", "myorg/repo2", "-metrics-file", path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`register_github_secret_secrets_total{host="` + serverHost(t, srv) + `",owner="myorg",result="written"} 2`,
+		`register_github_secret_api_calls_total{operation="CreateOrUpdateRepoSecret",status="201"} 2`,
+		`register_github_secret_run_success{command="register"} 1`,
+	} {
+		if !strings.Contains(string(got), want+"\n") {
+			t.Errorf("the metrics must contain %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRegister_auditLog(t *testing.T) {
+	srv := newFakeServer(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for _, value := range []string{"first", "second"} {
+		if _, err := runApp(t, srv, "-secret-name", "MY_SECRET", "-secret-value", value, "-repos", "myorg/repo1", "-audit-log", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// verifying needs no access to GitHub
+	out := new(bytes.Buffer)
+	if err := cli.NewApp(nil, out).Run(t.Context(), []string{"register-github-secret", "audit", "verify", "-output", "json", path}); err != nil {
+		t.Fatal(err)
+	}
+	var verification cli.AuditVerification
+	if err := json.Unmarshal(out.Bytes(), &verification); err != nil {
+		t.Fatal(err)
+	}
+	if verification.Entries != 2 {
+		t.Errorf("entries = %d; want 2", verification.Entries)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	var last audit.Entry
+	if err := json.Unmarshal([]byte(lines[1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Actor != "octocat" || last.Result != "updated" || !last.MatchesValue([]byte("second")) {
+		t.Errorf("last entry: %#v", last)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(b), `"result":"updated"`, `"result":"created"`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.NewApp(nil, io.Discard).Run(t.Context(), []string{"register-github-secret", "audit", "verify", path}); err == nil {
+		t.Error("the edited log must fail the verification")
+	}
+}
+
+func TestSealAndUpload(t *testing.T) {
+	srv := newFakeServer(t)
+	sealed, err := runApp(t, srv, "seal", "-secret-name", "MY_SECRET", "-secret-value", "sealed value", "-repos", "myorg/repo1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedFile := filepath.Join(t.TempDir(), "sealed.json")
+	if err := os.WriteFile(sealedFile, []byte(sealed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runApp(t, srv, "upload", "-sealed", sealedFile); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.RepoSecret("myorg", "repo1", "MY_SECRET"); got != "sealed value" {
+		t.Errorf("secret = %q; want %q", got, "sealed value")
+	}
+
+	previousKeyID := srv.KeyID()
+	if err := srv.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = runApp(t, srv, "upload", "-sealed", sealedFile)
+	mismatch := new(usecases.KeyMismatchError)
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("want KeyMismatchError but got %v", err)
+	}
+	want := &usecases.KeyMismatchError{Owner: "myorg", Repo: "repo1", SealedKeyID: previousKeyID, CurrentKeyID: srv.KeyID()}
+	if diff := cmp.Diff(want, mismatch); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+}
+
+func TestKeysExport(t *testing.T) {
+	srv := newFakeServer(t)
+	out, err := runApp(t, srv, "keys", "export", "-org", "myorg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyring cli.Keyring
+	if err := json.Unmarshal([]byte(out), &keyring); err != nil {
+		t.Fatal(err)
+	}
+	want := []cli.KeyringEntry{
+		{Owner: "myorg", Repo: "repo1", KeyID: srv.KeyID()},
+		{Owner: "myorg", Repo: "repo2", KeyID: srv.KeyID()},
+	}
+	if diff := cmp.Diff(want, keyring.Keys, cmpopts.IgnoreFields(cli.KeyringEntry{}, "FetchedAt", "Key")); diff != "" {
+		t.Errorf("keys (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,381 @@
+// Package audit keeps the local record of the secrets written, as JSON lines that cannot be edited unnoticed.
+//
+// Each entry holds the hash of the previous one, so that editing, inserting or removing an entry breaks the chain.
+// The sequence number and the hash of the last entry are kept in the head file next to the log,
+// so that removing the entries at the end is told from a log that simply has fewer entries.
+// The head is written after the entry, so a log one entry ahead of its head is the one a crash left, which Open repairs.
+// Anyone able to rewrite both files can still forge a consistent log; the head printed by Verify can be kept elsewhere to catch that.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EnvFile names the environment variable giving the default path of the audit log.
+const EnvFile = "REGISTER_GITHUB_SECRET_AUDIT_LOG"
+
+// The actions recorded by the commands.
+const (
+	ActionRegister = "register"
+	ActionUpload   = "upload"
+)
+
+const digestPrefix = "hmac-sha256:"
+
+// Entry records a write of a secret to a repository.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is the login of the user the token belongs to.
+	Actor string `json:"actor"`
+	// Host is empty for the default host.
+	Host       string `json:"host,omitempty"`
+	Owner      string `json:"owner"`
+	Repo       string `json:"repo"`
+	SecretName string `json:"secret_name"`
+	// Action is the command that wrote the secret: ActionRegister or ActionUpload.
+	Action string `json:"action"`
+	// ValueSalt and ValueDigest identify the value written without revealing it; see Digest.
+	ValueSalt   string `json:"value_salt"`
+	ValueDigest string `json:"value_digest"`
+	// Result is one of created, updated, skipped or failed.
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+	Seq      uint64 `json:"seq"`
+}
+
+// Digest returns the salt and the digest of the value.
+// The value is keyed with a fresh random salt, so that the same value has distinct digests across the entries
+// while a known value can still be checked against an entry with MatchesValue.
+func Digest(value []byte) (salt, digest string, err error) {
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate the salt: %w", err)
+	}
+	return hex.EncodeToString(b), digestWithSalt(b, value), nil
+}
+
+func digestWithSalt(salt, value []byte) string {
+	mac := hmac.New(sha256.New, salt)
+	_, _ = mac.Write(value)
+	return digestPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// MatchesValue tells whether the entry records the value.
+func (e *Entry) MatchesValue(value []byte) bool {
+	salt, err := hex.DecodeString(e.ValueSalt)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(e.ValueDigest), []byte(digestWithSalt(salt, value)))
+}
+
+// computeHash hashes the entry as encoded without its hash.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Head is the sequence number and the hash of the last entry; the zero Head stands for the empty log.
+type Head struct {
+	Hash string `json:"hash"`
+	Seq  uint64 `json:"seq"`
+}
+
+func headPath(path string) string { return path + ".head" }
+
+// Open opens the log at path to append to, creating it if missing.
+// The log is locked until closed, so the other processes wait to open it.
+// It is verified first, so that no entry is chained to a tampered one; only the stale head is repaired.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	if err = lockFile(f, true); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("lock audit log: %w", err)
+	}
+	head, err := verifyFile(path, f)
+	if staleErr := new(StaleHeadError); errors.As(err, &staleErr) {
+		err = writeHead(headPath(path), head)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Log{f: f, path: path, head: head}, nil
+}
+
+// Log is safe for concurrent use.
+type Log struct {
+	f    *os.File
+	path string
+	head Head
+	mux  sync.Mutex
+}
+
+// Append chains the entry to the last one and writes it; Seq, PrevHash and Hash of the entry are filled in,
+// and so is Time if zero.
+func (l *Log) Append(e Entry) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Seq = l.head.Seq + 1
+	e.PrevHash = l.head.Hash
+	var err error
+	if e.Hash, err = e.computeHash(); err != nil {
+		return fmt.Errorf("append to audit log: %w", err)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("append to audit log: %w", err)
+	}
+	if _, err = l.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append to audit log: %w", err)
+	}
+	if err = l.f.Sync(); err != nil {
+		return fmt.Errorf("append to audit log: %w", err)
+	}
+	// the entry is in the log from now on, so the next one is chained to it even if the head is not written
+	l.head = Head{Seq: e.Seq, Hash: e.Hash}
+	if err = writeHead(headPath(l.path), l.head); err != nil {
+		return fmt.Errorf("append to audit log: %w", err)
+	}
+	return nil
+}
+
+// Close releases the lock taken by Open.
+func (l *Log) Close() error { return l.f.Close() }
+
+// writeHead replaces the head file at once, so that a crash leaves either the previous head or the new one.
+func writeHead(path string, head Head) error {
+	b, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Verify walks the log at path and returns its head; a missing log is the empty one.
+// It returns TamperedError if an entry was edited, inserted or removed, and TruncatedError if the last entries were removed.
+// The log one entry ahead of its head is told by StaleHeadError, with the head of the log returned.
+func Verify(path string) (Head, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		wantHead, hasHead, headErr := readHead(path)
+		if headErr != nil {
+			return Head{}, headErr
+		}
+		if hasHead && wantHead.Seq > 0 {
+			return Head{}, &TruncatedError{Path: path, Want: wantHead.Seq}
+		}
+		return Head{}, nil
+	}
+	if err != nil {
+		return Head{}, fmt.Errorf("verify audit log: %w", err)
+	}
+	defer f.Close()
+	if err = lockFile(f, false); err != nil {
+		return Head{}, fmt.Errorf("lock audit log: %w", err)
+	}
+	return verifyFile(path, f)
+}
+
+// verifyFile verifies the log read from r against the head file; the log must be locked by the caller.
+func verifyFile(path string, r io.Reader) (Head, error) {
+	wantHead, hasHead, err := readHead(path)
+	if err != nil {
+		return Head{}, err
+	}
+	prev, head, err := walk(path, r)
+	if err != nil {
+		return Head{}, err
+	}
+	switch {
+	case head.Seq == wantHead.Seq+1 && prev == wantHead:
+		return head, &StaleHeadError{Path: path, Seq: head.Seq}
+	case !hasHead && head.Seq > 0:
+		return Head{}, &TamperedError{Path: path, Reason: ReasonHeadMissing}
+	case head.Seq < wantHead.Seq:
+		return Head{}, &TruncatedError{Path: path, Want: wantHead.Seq, Got: head.Seq}
+	case head.Seq > wantHead.Seq:
+		return Head{}, &TamperedError{Path: path, Line: wantHead.Seq + 1, Reason: ReasonNotInHead}
+	case head.Hash != wantHead.Hash:
+		return Head{}, &TamperedError{Path: path, Line: head.Seq, Reason: ReasonHeadMismatch}
+	}
+	return head, nil
+}
+
+func readHead(path string) (Head, bool, error) {
+	b, err := os.ReadFile(headPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return Head{}, false, nil
+	}
+	if err != nil {
+		return Head{}, false, fmt.Errorf("read audit log head: %w", err)
+	}
+	var head Head
+	if json.Unmarshal(b, &head) != nil {
+		return Head{}, false, &TamperedError{Path: headPath(path), Reason: ReasonMalformed}
+	}
+	return head, true, nil
+}
+
+// walk checks that each line is the entry chained to the previous one in its canonical encoding.
+// It returns the heads of the log without its last entry and of the whole log.
+func walk(path string, r io.Reader) (Head, Head, error) {
+	var prev, head Head
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// an entry is always written with its newline
+				return Head{}, Head{}, &TamperedError{Path: path, Line: head.Seq + 1, Reason: ReasonMalformed}
+			}
+			return prev, head, nil
+		}
+		if err != nil {
+			return Head{}, Head{}, fmt.Errorf("verify audit log: %w", err)
+		}
+		lineNo := head.Seq + 1
+		var e Entry
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if dec.Decode(&e) != nil {
+			return Head{}, Head{}, &TamperedError{Path: path, Line: lineNo, Reason: ReasonMalformed}
+		}
+		if canonical, marshalErr := json.Marshal(e); marshalErr != nil || !bytes.Equal(append(canonical, '\n'), line) {
+			return Head{}, Head{}, &TamperedError{Path: path, Line: lineNo, Reason: ReasonMalformed}
+		}
+		if e.Seq != lineNo {
+			return Head{}, Head{}, &TamperedError{Path: path, Line: lineNo, Reason: ReasonSeqMismatch}
+		}
+		if e.PrevHash != head.Hash {
+			return Head{}, Head{}, &TamperedError{Path: path, Line: lineNo, Reason: ReasonChainBroken}
+		}
+		if hash, hashErr := e.computeHash(); hashErr != nil || hash != e.Hash {
+			return Head{}, Head{}, &TamperedError{Path: path, Line: lineNo, Reason: ReasonHashMismatch}
+		}
+		prev, head = head, Head{Seq: e.Seq, Hash: e.Hash}
+	}
+}
+
+// The reasons TamperedError gives.
+const (
+	ReasonMalformed    = "malformed entry"
+	ReasonSeqMismatch  = "unexpected sequence number"
+	ReasonChainBroken  = "not chained to the previous entry"
+	ReasonHashMismatch = "hash does not match the entry"
+	ReasonHeadMissing  = "head file is missing"
+	ReasonHeadMismatch = "last entry does not match the head"
+	ReasonNotInHead    = "entry is not recorded in the head"
+)
+
+// TamperedError tells that the log was edited; Line is the first entry found to be wrong, or zero for the log as a whole.
+type TamperedError struct {
+	Path   string
+	Reason string
+	Line   uint64
+}
+
+func (e *TamperedError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("audit log %s is tampered: %s", e.Path, e.Reason)
+	}
+	return fmt.Sprintf("audit log %s is tampered at line %d: %s", e.Path, e.Line, e.Reason)
+}
+
+func (e *TamperedError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path), slog.Uint64("line", e.Line), slog.String("reason", e.Reason))
+}
+
+func (e *TamperedError) Is(err error) bool {
+	thatErr := new(TamperedError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Path == thatErr.Path && e.Line == thatErr.Line && e.Reason == thatErr.Reason
+}
+
+// StaleHeadError tells that the last entry of the log is chained to the head but not recorded in it,
+// as a crash between writing the entry and the head leaves; the entry itself may still be forged.
+type StaleHeadError struct {
+	Path string
+	Seq  uint64
+}
+
+func (e *StaleHeadError) Error() string {
+	return fmt.Sprintf("audit log %s has a stale head: entry %d is not recorded in the head", e.Path, e.Seq)
+}
+
+func (e *StaleHeadError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path), slog.Uint64("seq", e.Seq))
+}
+
+func (e *StaleHeadError) Is(err error) bool {
+	thatErr := new(StaleHeadError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Path == thatErr.Path && e.Seq == thatErr.Seq
+}
+
+// TruncatedError tells that the log has fewer entries than its head records.
+type TruncatedError struct {
+	Path string
+	Want uint64
+	Got  uint64
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("audit log %s is truncated: %d entries are recorded but %d are found", e.Path, e.Want, e.Got)
+}
+
+func (e *TruncatedError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path), slog.Uint64("want", e.Want), slog.Uint64("got", e.Got))
+}
+
+func (e *TruncatedError) Is(err error) bool {
+	thatErr := new(TruncatedError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Path == thatErr.Path && e.Want == thatErr.Want && e.Got == thatErr.Got
+}
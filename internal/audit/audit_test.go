@@ -0,0 +1,260 @@
+package audit_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/audit"
+)
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		wantErr error
+		tamper  func(t *testing.T, path string)
+		name    string
+	}{
+		{name: "intact"},
+		{
+			name: "edited",
+			tamper: func(t *testing.T, path string) {
+				rewrite(t, path, func(lines [][]byte) [][]byte {
+					lines[1] = bytes.Replace(lines[1], []byte(`"result":"created"`), []byte(`"result":"failed"`), 1)
+					return lines
+				})
+			},
+			wantErr: &audit.TamperedError{Path: "audit.jsonl", Line: 2, Reason: audit.ReasonHashMismatch},
+		},
+		{
+			name: "entry removed",
+			tamper: func(t *testing.T, path string) {
+				rewrite(t, path, func(lines [][]byte) [][]byte { return append(lines[:1], lines[2:]...) })
+			},
+			wantErr: &audit.TamperedError{Path: "audit.jsonl", Line: 2, Reason: audit.ReasonSeqMismatch},
+		},
+		{
+			name: "reformatted",
+			tamper: func(t *testing.T, path string) {
+				rewrite(t, path, func(lines [][]byte) [][]byte {
+					lines[0] = bytes.Replace(lines[0], []byte(`,"actor"`), []byte(`, "actor"`), 1)
+					return lines
+				})
+			},
+			wantErr: &audit.TamperedError{Path: "audit.jsonl", Line: 1, Reason: audit.ReasonMalformed},
+		},
+		{
+			name: "truncated",
+			tamper: func(t *testing.T, path string) {
+				rewrite(t, path, func(lines [][]byte) [][]byte { return lines[:1] })
+			},
+			wantErr: &audit.TruncatedError{Path: "audit.jsonl", Want: 3, Got: 1},
+		},
+		{
+			name: "cut in the middle of an entry",
+			tamper: func(t *testing.T, path string) {
+				rewrite(t, path, func(lines [][]byte) [][]byte {
+					lines[2] = lines[2][:10]
+					return lines
+				})
+			},
+			wantErr: &audit.TamperedError{Path: "audit.jsonl", Line: 3, Reason: audit.ReasonMalformed},
+		},
+		{
+			name: "removed",
+			tamper: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: &audit.TruncatedError{Path: "audit.jsonl", Want: 3},
+		},
+		{
+			name: "head removed",
+			tamper: func(t *testing.T, path string) {
+				if err := os.Remove(path + ".head"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: &audit.TamperedError{Path: "audit.jsonl", Reason: audit.ReasonHeadMissing},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			path := "audit.jsonl"
+			writeEntries(t, path, 3)
+			if tc.tamper != nil {
+				tc.tamper(t, path)
+			}
+			head, gotErr := audit.Verify(path)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if tc.wantErr == nil && head.Seq != 3 {
+				t.Errorf("head: want 3 entries but got %d", head.Seq)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEntries(t, path, 1)
+	// reopening continues the chain
+	writeEntries(t, path, 1)
+	head, err := audit.Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 2 {
+		t.Errorf("head: want 2 entries but got %d", head.Seq)
+	}
+	rewrite(t, path, func(lines [][]byte) [][]byte { return lines[:1] })
+	if _, err := audit.Open(path); err == nil {
+		t.Error("a tampered log must not be appended to")
+	}
+}
+
+func TestOpen_staleHead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEntries(t, path, 1)
+	staleHead, err := os.ReadFile(path + ".head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntries(t, path, 1)
+	// as if the process crashed before writing the head of the second entry
+	if err := os.WriteFile(path+".head", staleHead, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	head, gotErr := audit.Verify(path)
+	if diff := assertions.DiffErrorsConservatively(&audit.StaleHeadError{Path: path, Seq: 2}, gotErr); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+	if head.Seq != 2 {
+		t.Errorf("head: want 2 entries but got %d", head.Seq)
+	}
+	writeEntries(t, path, 1)
+	if head, err = audit.Verify(path); err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 3 {
+		t.Errorf("head: want 3 entries but got %d", head.Seq)
+	}
+}
+
+func TestLog_Append_headNotWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the head cannot be replaced by a file while a directory is in its place
+	if err := os.Mkdir(path+".head", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(audit.Entry{Actor: "octocat", Owner: "aereal", Repo: "repo1", Result: "created"}); err == nil {
+		t.Fatal("want an error for the head not written")
+	}
+	if err := os.Remove(path + ".head"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(audit.Entry{Actor: "octocat", Owner: "aereal", Repo: "repo2", Result: "created"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	head, err := audit.Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 2 {
+		t.Errorf("head: want 2 entries but got %d", head.Seq)
+	}
+}
+
+func TestOpen_concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				l, err := audit.Open(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := l.Append(audit.Entry{Actor: "octocat", Owner: "aereal", Repo: "repo1", Result: "created"}); err != nil {
+					t.Error(err)
+				}
+				_ = l.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	head, err := audit.Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 20 {
+		t.Errorf("head: want 20 entries but got %d", head.Seq)
+	}
+}
+
+func TestEntry_MatchesValue(t *testing.T) {
+	salt, digest, err := audit.Digest([]byte("blah blah"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &audit.Entry{ValueSalt: salt, ValueDigest: digest}
+	if !e.MatchesValue([]byte("blah blah")) {
+		t.Error("the entry must match the value digested")
+	}
+	if e.MatchesValue([]byte("other")) {
+		t.Error("the entry must not match another value")
+	}
+	otherSalt, otherDigest, err := audit.Digest([]byte("blah blah"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherSalt == salt || otherDigest == digest {
+		t.Error("the same value must be digested differently each time")
+	}
+}
+
+func writeEntries(t *testing.T, path string, n int) {
+	t.Helper()
+	l, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for range n {
+		salt, digest, err := audit.Digest([]byte("blah blah"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := audit.Entry{Actor: "octocat", Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: audit.ActionRegister, ValueSalt: salt, ValueDigest: digest, Result: "created"}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func rewrite(t *testing.T, path string, edit func(lines [][]byte) [][]byte) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if err := os.WriteFile(path, bytes.Join(edit(lines[:len(lines)-1]), nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
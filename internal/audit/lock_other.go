@@ -0,0 +1,8 @@
+//go:build !unix
+
+package audit
+
+import "os"
+
+// lockFile does nothing where flock(2) is unavailable; the processes must not append to the same log at once there.
+func lockFile(*os.File, bool) error { return nil }
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aereal/register-github-secret/internal/audit"
+)
+
+type IdentifyActorUsecase interface {
+	DoIdentifyActor(ctx context.Context) (string, error)
+}
+
+func bindAuditFlag(fs *flag.FlagSet, path *string) {
+	fs.StringVar(path, "audit-log", os.Getenv(audit.EnvFile), "path to the tamper-evident log to append each write attempted to, with the user the token belongs to (env "+audit.EnvFile+")")
+}
+
+// auditTrail appends the writes of a run to the audit log; the nil auditTrail records nothing.
+type auditTrail struct {
+	log *audit.Log
+	// actors are the logins the tokens of the accounts belong to, identified before any write.
+	actors map[Account]string
+	errs   []error
+	mux    sync.Mutex
+}
+
+// openAuditTrail opens the audit log at path and identifies the actors of the accounts of the targets.
+// It returns nil if path is empty.
+func openAuditTrail(ctx context.Context, provider UsecaseProvider, path string, targets []qualifiedRepo) (*auditTrail, error) {
+	if path == "" {
+		return nil, nil
+	}
+	actors := map[Account]string{}
+	for _, r := range targets {
+		account := r.account()
+		if _, ok := actors[account]; ok {
+			continue
+		}
+		uc, err := provider.IdentifyActorUsecase(ctx, account)
+		if err == nil {
+			actors[account], err = uc.DoIdentifyActor(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("identify the actor of %s: %w", account.Owner, err)
+		}
+	}
+	l, err := audit.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &auditTrail{log: l, actors: actors}, nil
+}
+
+// record appends the outcome of the write of value by the action; value is the one sent to GitHub.
+func (t *auditTrail) record(action string, tr TargetReport, value []byte) {
+	if t == nil {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	salt, digest, err := audit.Digest(value)
+	if err != nil {
+		t.errs = append(t.errs, err)
+		return
+	}
+	e := audit.Entry{
+		Actor:       t.actors[Account{Host: tr.Host, Owner: tr.Owner}],
+		Host:        tr.Host,
+		Owner:       tr.Owner,
+		Repo:        tr.Repo,
+		SecretName:  tr.SecretName,
+		Action:      action,
+		ValueSalt:   salt,
+		ValueDigest: digest,
+		Result:      tr.Action,
+	}
+	if tr.Error != nil {
+		e.Error = tr.Error.Message
+	}
+	if err = t.log.Append(e); err != nil {
+		t.errs = append(t.errs, err)
+	}
+}
+
+// close passes err through, joined with the failures to record the writes.
+func (t *auditTrail) close(err error) error {
+	if t == nil {
+		return err
+	}
+	if auditErr := errors.Join(append(t.errs, t.log.Close())...); auditErr != nil {
+		return errors.Join(err, auditErr)
+	}
+	return err
+}
+
+func (a *App) runAudit(name string, args []string) error {
+	if len(args) == 0 {
+		return &UnknownAuditCommandError{}
+	}
+	switch args[0] {
+	case "verify":
+		return a.runAuditVerify(name+" verify", args[1:])
+	default:
+		return &UnknownAuditCommandError{Name: args[0]}
+	}
+}
+
+// AuditVerification is the result of audit verify.
+type AuditVerification struct {
+	Path string `json:"path"`
+	// Head is the hash of the last entry, to be kept elsewhere to catch a log forged as a whole.
+	Head    string `json:"head"`
+	Entries uint64 `json:"entries"`
+}
+
+func (a *App) runAuditVerify(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] [AUDIT_LOG]\n", name)
+		fs.PrintDefaults()
+	}
+	output := OutputFormatText
+	fs.Var(&output, "output", "result output format written to stdout: text or json")
+	err := parseFlags(fs, args, nil)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	path := os.Getenv(audit.EnvFile)
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		return ErrAuditLogRequired
+	}
+	head, err := audit.Verify(path)
+	if err != nil {
+		return err
+	}
+	result := AuditVerification{Path: path, Head: head.Hash, Entries: head.Seq}
+	if output == OutputFormatJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	_, err = fmt.Fprintf(a.stdout, "%s: %d entries verified, head %s\n", result.Path, result.Entries, result.Head)
+	return err
+}
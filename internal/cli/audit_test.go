@@ -0,0 +1,174 @@
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/audit"
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApp_Run_auditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv(audit.EnvFile, path)
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard)
+	_ = app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"})
+
+	entries := readAuditEntries(t, path)
+	want := []audit.Entry{
+		{Actor: "octocat", Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: audit.ActionRegister, Result: "created"},
+		{Actor: "octocat", Owner: "aereal", Repo: "repo2", SecretName: "MY_SECRET", Action: audit.ActionRegister, Result: "failed", Error: errFailed.Error()},
+	}
+	ignoreChain := cmpopts.IgnoreFields(audit.Entry{}, "Time", "ValueSalt", "ValueDigest", "PrevHash", "Hash", "Seq")
+	sortByRepo := cmpopts.SortSlices(func(a, b audit.Entry) bool { return a.Repo < b.Repo })
+	if diff := cmp.Diff(want, entries, ignoreChain, sortByRepo); diff != "" {
+		t.Errorf("entries (-want, +got):\n%s", diff)
+	}
+	for _, e := range entries {
+		if !e.MatchesValue([]byte("blah blah")) {
+			t.Errorf("the entry of %s must record the value written", e.Repo)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	if err := cli.NewApp(nil, out).Run(t.Context(), []string{"app", "audit", "verify", "-output", "json"}); err != nil {
+		t.Fatal(err)
+	}
+	var got cli.AuditVerification
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != path || got.Entries != 2 || got.Head != entries[1].Hash {
+		t.Errorf("verification: %#v", got)
+	}
+}
+
+func TestApp_Run_auditLogPreflightSkipped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	denied := &usecases.InsufficientPermissionError{Owner: "aereal", Repo: "repo2", Reason: "token lacks the repo scope"}
+	mockCheck := NewMockCheckRepositoryAccessUsecase(ctrl)
+	mockCheck.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(&usecases.RepositoryAccess{WriteVerified: true}, nil).Times(1)
+	mockCheck.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(nil, denied).Times(1)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	newProvider := func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return &usecaseProvider{uc: mockUsecase, check: mockCheck}, nil
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	app := cli.NewApp(newProvider, io.Discard)
+	_ = app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-continue-on-preflight-failure", "-repos", "aereal/repo1", "-repos", "aereal/repo2", "-audit-log", path})
+
+	want := []audit.Entry{
+		{Actor: "octocat", Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: audit.ActionRegister, Result: "created"},
+		{Actor: "octocat", Owner: "aereal", Repo: "repo2", SecretName: "MY_SECRET", Action: audit.ActionRegister, Result: "skipped", Error: denied.Error()},
+	}
+	ignoreChain := cmpopts.IgnoreFields(audit.Entry{}, "Time", "ValueSalt", "ValueDigest", "PrevHash", "Hash", "Seq")
+	sortByRepo := cmpopts.SortSlices(func(a, b audit.Entry) bool { return a.Repo < b.Repo })
+	if diff := cmp.Diff(want, readAuditEntries(t, path), ignoreChain, sortByRepo); diff != "" {
+		t.Errorf("entries (-want, +got):\n%s", diff)
+	}
+}
+
+func TestApp_Run_auditLogActorUnknown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockActor := NewMockIdentifyActorUsecase(ctrl)
+	mockActor.EXPECT().DoIdentifyActor(gomock.Any()).Return("", errFailed).Times(1)
+	newProvider := func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return &usecaseProvider{uc: mockUsecase, actor: mockActor}, nil
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	app := cli.NewApp(newProvider, io.Discard)
+	gotErr := app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-audit-log", path})
+	if diff := assertions.DiffErrorsConservatively(errFailed, gotErr); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("nothing must be recorded without the actor: %v", err)
+	}
+}
+
+func TestApp_Run_auditVerify(t *testing.T) {
+	dir := t.TempDir()
+	intact := filepath.Join(dir, "intact.jsonl")
+	truncated := filepath.Join(dir, "truncated.jsonl")
+	for _, path := range []string{intact, truncated} {
+		l, err := audit.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, repo := range []string{"repo1", "repo2"} {
+			if err := l.Append(audit.Entry{Actor: "octocat", Owner: "aereal", Repo: repo, SecretName: "MY_SECRET", Action: audit.ActionRegister, Result: "created"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncated, b[:bytes.IndexByte(b, '\n')+1], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		wantErr error
+		name    string
+		args    []string
+	}{
+		{name: "intact", args: []string{"verify", intact}},
+		{name: "truncated", args: []string{"verify", truncated}, wantErr: &audit.TruncatedError{Path: truncated, Want: 2, Got: 1}},
+		{name: "no log", args: []string{"verify"}, wantErr: cli.ErrAuditLogRequired},
+		{name: "no subcommand", wantErr: &cli.UnknownAuditCommandError{}},
+		{name: "unknown subcommand", args: []string{"rotate"}, wantErr: &cli.UnknownAuditCommandError{Name: "rotate"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(audit.EnvFile, "")
+			app := cli.NewApp(nil, io.Discard)
+			gotErr := app.Run(t.Context(), append([]string{"app", "audit"}, tc.args...))
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func readAuditEntries(t *testing.T, path string) []audit.Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []audit.Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
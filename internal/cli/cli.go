@@ -1,88 +1,582 @@
-//go:generate go tool mockgen -destination ./usecase_mock_test.go -package cli_test -typed -write_command_comment=false github.com/aereal/register-github-secret/internal/cli RegisterRepositorySecretUsecase
+//go:generate go tool mockgen -destination ./usecase_mock_test.go -package cli_test -typed -write_command_comment=false github.com/aereal/register-github-secret/internal/cli RegisterRepositorySecretUsecase,CheckRepositoryAccessUsecase,GetRepositoryPublicKeyUsecase,UploadSealedRepositorySecretUsecase,ListOrganizationRepositoriesUsecase,CheckSecretQuotaUsecase,IdentifyActorUsecase
 
 package cli
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aereal/register-github-secret/internal/audit"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/aereal/register-github-secret/internal/metrics"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
 	set "github.com/hashicorp/go-set/v3"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type RegisterRepositorySecretUsecase interface {
-	DoRegisterRepositorySecret(ctx context.Context, repoOwner string, repoName string, secretName string, plainMsg string) error
+	DoRegisterRepositorySecret(ctx context.Context, repoOwner string, repoName string, secretName string, plainMsg string) (*usecases.RegisterResult, error)
 }
 
-func NewApp(uc RegisterRepositorySecretUsecase) *App {
-	return &App{uc: uc}
+type CheckRepositoryAccessUsecase interface {
+	DoCheckRepositoryAccess(ctx context.Context, repoOwner string, repoName string) (*usecases.RepositoryAccess, error)
+}
+
+type CheckSecretQuotaUsecase interface {
+	DoCheckSecretQuota(ctx context.Context, repoOwner string, repoName string, secretNames []string) error
+}
+
+type GetRepositoryPublicKeyUsecase interface {
+	DoGetRepositoryPublicKey(ctx context.Context, repoOwner string, repoName string) (*github.PublicKey, error)
+}
+
+type UploadSealedRepositorySecretUsecase interface {
+	DoUploadSealedRepositorySecret(ctx context.Context, repoOwner string, repoName string, sealed *usecases.SealedSecret) (*usecases.RegisterResult, error)
+}
+
+type ListOrganizationRepositoriesUsecase interface {
+	DoListOrganizationRepositories(ctx context.Context, org string) ([]string, error)
+}
+
+// Account identifies a repository owner on a GitHub host.
+type Account struct {
+	// Host is the GitHub host such as ghe.example.com; empty means the default host given by UsecaseConfig.GitHubURL.
+	Host  string
+	Owner string
+}
+
+// UsecaseProvider hands out the usecases authenticated for each account.
+type UsecaseProvider interface {
+	RegisterRepositorySecretUsecase(ctx context.Context, account Account) (RegisterRepositorySecretUsecase, error)
+	CheckRepositoryAccessUsecase(ctx context.Context, account Account) (CheckRepositoryAccessUsecase, error)
+	CheckSecretQuotaUsecase(ctx context.Context, account Account) (CheckSecretQuotaUsecase, error)
+	GetRepositoryPublicKeyUsecase(ctx context.Context, account Account) (GetRepositoryPublicKeyUsecase, error)
+	UploadSealedRepositorySecretUsecase(ctx context.Context, account Account) (UploadSealedRepositorySecretUsecase, error)
+	ListOrganizationRepositoriesUsecase(ctx context.Context, account Account) (ListOrganizationRepositoriesUsecase, error)
+	IdentifyActorUsecase(ctx context.Context, account Account) (IdentifyActorUsecase, error)
+}
+
+// UsecaseConfig holds the options that affect how the usecase talks to GitHub.
+type UsecaseConfig struct {
+	// GitHubURL is the URL of the GitHub Enterprise Server or its API; empty means github.com.
+	GitHubURL string
+	// TokenFile is the path to a file containing the token, tried before the other credential sources.
+	TokenFile string
+	// TokenMapFile is the path to a JSON file mapping each owner to the source of its token; every owner must be mapped when set.
+	TokenMapFile string
+	// AppPrivateKeyFile is the path to the PEM encoded private key of the GitHub App identified by AppID.
+	AppPrivateKeyFile string
+	// CABundleFile is a PEM file of CA certificates trusted in addition to the system ones.
+	CABundleFile string
+	// ClientCertFile and ClientKeyFile are the certificate and key used for mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+	UserAgent      string
+	// KeyCacheDir is where the public keys are cached; empty means the user cache directory.
+	KeyCacheDir string
+	// AppID enables GitHub App authentication when non-zero.
+	AppID       int64
+	MaxAttempts int
+	// RequestTimeout bounds each GitHub API request; zero means no limit.
+	RequestTimeout time.Duration
+	// KeyCacheTTL is how long a cached public key is trusted; zero disables the cache.
+	KeyCacheTTL time.Duration
+}
+
+type NewUsecaseProviderFunc func(ctx context.Context, cfg UsecaseConfig) (UsecaseProvider, error)
+
+// SecretRegistry is told the secret values given on the command line, so that they are kept out of the logs.
+type SecretRegistry interface {
+	Add(values ...string)
+}
+
+type AppOption func(*App)
+
+func WithSecretRegistry(r SecretRegistry) AppOption {
+	return func(a *App) { a.secrets = r }
+}
+
+func NewApp(newProvider NewUsecaseProviderFunc, stdout io.Writer, opts ...AppOption) *App {
+	a := &App{newProvider: newProvider, stdout: stdout, metrics: metrics.New()}
+	for _, f := range opts {
+		f(a)
+	}
+	return a
 }
 
 type App struct {
-	uc RegisterRepositorySecretUsecase
+	newProvider NewUsecaseProviderFunc
+	stdout      io.Writer
+	metrics     RunMetrics
+	// secrets is nil unless the secret values are registered.
+	secrets SecretRegistry
+	// setupLogger is nil unless the logging options are applied.
+	setupLogger LoggerSetup
+	// setupTracer is nil unless the tracing options are applied.
+	setupTracer TracerSetup
+	// metricsFile is set by -metrics-file of the subcommand run.
+	metricsFile string
+}
+
+var secretValueUsage = fmt.Sprintf("secret value; one shorter than %d bytes is masked in the logs only where it makes up a whole value, not inside other text", log.MinMaskedLength)
+
+// registerSecret has the value masked in the logs, warning that a value too short to be masked wherever it appears may leak.
+func (a *App) registerSecret(ctx context.Context, value string) {
+	if a.secrets == nil {
+		return
+	}
+	a.secrets.Add(value)
+	if value != "" && len(value) < log.MinMaskedLength {
+		slog.WarnContext(ctx, "the secret value is too short to be masked wherever it appears in the logs; it is masked only where it makes up a whole value",
+			slog.Int("secret.min_masked_length", log.MinMaskedLength),
+		)
+	}
+}
+
+// registerConfig is the parsed command line of a run.
+type registerConfig struct {
+	repos       *set.Set[qualifiedRepo]
+	secretName  string
+	secretValue string
+	// auditLog is the path to the audit log; empty means the writes are not recorded.
+	auditLog  string
+	usecase   UsecaseConfig
+	timeout   time.Duration
+	preflight bool
+	// normalizeSecretName rewrites the secret name into the form GitHub accepts instead of refusing it.
+	normalizeSecretName bool
+	// continueOnPreflightFailure writes to the repositories that passed the preflight instead of aborting the run.
+	continueOnPreflightFailure bool
 }
 
+// Run dispatches to the subcommand named by args[1]; without a known subcommand the secret is registered.
+// The metrics of the run are written when it finishes if -metrics-file is given.
 func (a *App) Run(ctx context.Context, args []string) error {
-	fs := flag.NewFlagSet(filepath.Base(args[0]), flag.ContinueOnError)
+	startedAt := time.Now()
+	command, err := a.dispatch(ctx, args)
+	return a.writeMetrics(ctx, command, startedAt, err)
+}
+
+// dispatch runs the subcommand and returns its name used as the command label of the metrics.
+func (a *App) dispatch(ctx context.Context, args []string) (string, error) {
+	name := filepath.Base(args[0])
+	if len(args) > 1 {
+		switch args[1] {
+		case "seal":
+			return args[1], a.runSeal(ctx, name+" seal", args[2:])
+		case "upload":
+			return args[1], a.runUpload(ctx, name+" upload", args[2:])
+		case "keys":
+			return args[1], a.runKeys(ctx, name+" keys", args[2:])
+		case "audit":
+			return args[1], a.runAudit(name+" audit", args[2:])
+		}
+	}
+	return "register", a.runRegister(ctx, name, args[1:])
+}
+
+func (a *App) runRegister(ctx context.Context, name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	var (
-		secretName  string
-		secretValue string
-		repos       = set.New[qualifiedRepo](0)
+		cfg    registerConfig
+		output = OutputFormatText
 	)
-	fs.Func("repos", "repository name list", func(s string) error {
+	repos := bindReposFlag(fs)
+	fs.StringVar(&cfg.secretName, "secret-name", "", "secret name")
+	fs.StringVar(&cfg.secretValue, "secret-value", "", secretValueUsage)
+	fs.BoolVar(&cfg.normalizeSecretName, "normalize-secret-name", false, normalizeSecretNameUsage)
+	bindUsecaseFlags(fs, &cfg.usecase)
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "timeout for the whole run (0 means no timeout)")
+	fs.Var(&output, "output", "result output format written to stdout: text or json")
+	fs.BoolVar(&cfg.preflight, "preflight", true, "check that every repository is writable before writing any secret; whether it has room for the secret is checked regardless")
+	fs.BoolVar(&cfg.continueOnPreflightFailure, "continue-on-preflight-failure", false, "write to the repositories that passed the preflight instead of aborting")
+	bindAuditFlag(fs, &cfg.auditLog)
+	logs := bindLogFlags(fs)
+	traces := bindTraceFlags(fs)
+	a.bindMetricsFlag(fs)
+	report := &RunReport{StartedAt: time.Now()}
+	err := parseFlags(fs, args, repos)
+	if err == nil {
+		err = a.applyLogFlags(logs)
+	}
+	if err == nil {
+		err = a.applyTraceFlags(ctx, traces)
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return nil
+	case err == nil:
+		a.registerSecret(ctx, cfg.secretValue)
+		cfg.repos = repos.set
+		err = traceRun(ctx, name, func(ctx context.Context) error { return a.register(ctx, report, cfg) })
+	}
+	a.countSecrets(report, cfg.usecase)
+	return a.writeReport(report, output, err)
+}
+
+// writeReport writes the report of the run in the format and passes err through.
+func (a *App) writeReport(report *RunReport, output OutputFormat, err error) error {
+	if output != OutputFormatJSON {
+		return err
+	}
+	report.finish(time.Now(), err)
+	if writeErr := report.write(a.stdout); writeErr != nil {
+		return errors.Join(err, writeErr)
+	}
+	return err
+}
+
+// bindUsecaseFlags defines the flags that tell how to talk to GitHub, shared by the subcommands.
+func bindUsecaseFlags(fs *flag.FlagSet, cfg *UsecaseConfig) {
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", 3, "maximum number of attempts for each GitHub API call")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", 30*time.Second, "timeout for each GitHub API request (0 means no timeout)")
+	fs.StringVar(&cfg.KeyCacheDir, "key-cache-dir", "", "directory to cache the public keys of the repositories (defaults to the user cache directory)")
+	fs.DurationVar(&cfg.KeyCacheTTL, "key-cache-ttl", 24*time.Hour, "how long a cached public key is used (0 disables the cache)")
+	fs.StringVar(&cfg.GitHubURL, "github-url", os.Getenv("GITHUB_API_URL"), "URL of the GitHub Enterprise Server or its API (defaults to GITHUB_API_URL, then github.com)")
+	fs.StringVar(&cfg.CABundleFile, "ca-bundle", "", "path to a PEM file of CA certificates trusted in addition to the system ones")
+	fs.StringVar(&cfg.ClientCertFile, "client-cert", "", "path to the PEM encoded client certificate for mutual TLS")
+	fs.StringVar(&cfg.ClientKeyFile, "client-key", "", "path to the PEM encoded private key of -client-cert")
+	fs.StringVar(&cfg.UserAgent, "user-agent", "register-github-secret", "User-Agent header sent to GitHub")
+	fs.StringVar(&cfg.TokenFile, "token-file", "", "path to a file containing the GitHub token (defaults to GH_TOKEN, GITHUB_TOKEN, gh CLI and git credential helper in this order)")
+	fs.StringVar(&cfg.TokenMapFile, "token-map", "", `path to a JSON file mapping each owner ("OWNER" or "HOST/OWNER") to its token source ("env:NAME", "file:PATH", "gh" or "git")`)
+	fs.Int64Var(&cfg.AppID, "app-id", 0, "authenticate as the installation of the GitHub App with this ID")
+	fs.StringVar(&cfg.AppPrivateKeyFile, "app-private-key-file", "", "path to the PEM encoded private key of the GitHub App")
+}
+
+// defaultHost returns the host of GitHubURL, which the repositories given without a host are on.
+func (cfg UsecaseConfig) defaultHost() (string, error) {
+	endpoint, err := ghclient.ParseEndpoint(cfg.GitHubURL)
+	if err != nil {
+		return "", err
+	}
+	return endpoint.Host, nil
+}
+
+func (cfg UsecaseConfig) validate() error {
+	if cfg.MaxAttempts < 1 {
+		return &InvalidMaxAttemptsError{Value: cfg.MaxAttempts}
+	}
+	if (cfg.AppID == 0) != (cfg.AppPrivateKeyFile == "") {
+		return ErrIncompleteAppCredentials
+	}
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return ErrIncompleteClientCertificate
+	}
+	if cfg.TokenMapFile != "" {
+		switch {
+		case cfg.TokenFile != "":
+			return &ConflictingFlagsError{Flag: "token-map", Other: "token-file"}
+		case cfg.AppID != 0:
+			return &ConflictingFlagsError{Flag: "token-map", Other: "app-id"}
+		}
+	}
+	return nil
+}
+
+// reposFlag collects the values of -repos.
+// The flag package formats the error with %v, so err keeps the typed one to report it.
+type reposFlag struct {
+	set *set.Set[qualifiedRepo]
+	err error
+}
+
+func bindReposFlag(fs *flag.FlagSet) *reposFlag {
+	f := &reposFlag{set: set.New[qualifiedRepo](0)}
+	fs.Func("repos", "repository name list; each is OWNER/REPO or HOST/OWNER/REPO", func(s string) error {
 		qr := new(qualifiedRepo)
 		if err := qr.Set(s); err != nil {
+			f.err = fmt.Errorf("invalid value %q for flag -repos: %w", s, err)
 			return err
 		}
-		_ = repos.Insert(*qr)
+		_ = f.set.Insert(*qr)
 		return nil
 	})
-	fs.StringVar(&secretName, "secret-name", "", "secret name")
-	fs.StringVar(&secretValue, "secret-value", "", "secret value")
-	err := fs.Parse(args[1:])
+	return f
+}
+
+// parseFlags parses args and returns flag.ErrHelp as is, and the other errors as the ones to report.
+// repos may be nil if the command has no -repos.
+func parseFlags(fs *flag.FlagSet, args []string, repos *reposFlag) error {
+	err := fs.Parse(args)
 	switch {
 	case errors.Is(err, flag.ErrHelp):
-		return nil
-	case err != nil:
 		return err
+	case repos != nil && repos.err != nil:
+		return repos.err
+	case err != nil:
+		return &InvalidFlagError{Err: err}
+	default:
+		return nil
 	}
-	if secretName == "" {
-		return ErrSecretNameRequired
+}
+
+func (a *App) register(ctx context.Context, report *RunReport, cfg registerConfig) error {
+	var err error
+	cfg.secretName, err = resolveSecretName(ctx, cfg.secretName, cfg.normalizeSecretName)
+	if err != nil {
+		return err
 	}
-	if secretValue == "" {
+	if cfg.secretValue == "" {
 		return ErrSecretValueRequired
 	}
-	eg, ctx := errgroup.WithContext(ctx)
-	for r := range repos.Items() {
-		owner := r.Owner
-		repoName := r.Repo
-		eg.Go(func() error {
-			return a.uc.DoRegisterRepositorySecret(ctx, owner, repoName, secretName, secretValue)
-		})
+	if err = usecases.CheckSecretSize([]byte(cfg.secretValue)); err != nil {
+		return err
+	}
+	if err = cfg.usecase.validate(); err != nil {
+		return err
+	}
+	defaultHost, err := cfg.usecase.defaultHost()
+	if err != nil {
+		return err
+	}
+	targets := normalizeRepos(cfg.repos.Items(), defaultHost)
+	total := len(targets)
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	provider, err := a.newProvider(ctx, cfg.usecase)
+	if err != nil {
+		return err
 	}
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("usecases.NewRegisterRepositorySecret.Do: %w", err)
+	trail, err := openAuditTrail(ctx, provider, cfg.auditLog, targets)
+	if err != nil {
+		return err
+	}
+	out := &outcomes{report: report}
+	failures, unverified := a.preflight(ctx, provider, targets, func(qualifiedRepo) []string { return []string{cfg.secretName} }, cfg.preflight)
+	if len(failures) > 0 && !cfg.continueOnPreflightFailure {
+		preflightErr := &PreflightError{Failures: make([]error, 0, len(failures))}
+		for _, r := range targets {
+			tr := TargetReport{Host: r.Host, Owner: r.Owner, Repo: r.Repo, SecretName: cfg.secretName, Action: targetActionSkipped, WriteUnverified: unverified[r]}
+			if failure, ok := failures[r]; ok {
+				tr.Error = newErrorReport(failure)
+				preflightErr.Failures = append(preflightErr.Failures, fmt.Errorf("%s: %w", r.String(), failure))
+			}
+			report.Targets = append(report.Targets, tr)
+		}
+		return trail.close(preflightErr)
+	}
+	targets = slices.DeleteFunc(targets, func(r qualifiedRepo) bool {
+		failure, ok := failures[r]
+		if ok {
+			tr := TargetReport{Host: r.Host, Owner: r.Owner, Repo: r.Repo, SecretName: cfg.secretName, Action: targetActionSkipped, Error: newErrorReport(failure)}
+			trail.record(audit.ActionRegister, tr, []byte(cfg.secretValue))
+			out.record(ctx, r.String(), tr, failure)
+		}
+		return ok
+	})
+	forEachAccount(ctx, targets, provider.RegisterRepositorySecretUsecase, func(r qualifiedRepo, uc RegisterRepositorySecretUsecase, ucErr error) {
+		targetCtx, span := startTargetSpan(ctx, r, attribute.String("secret.name", cfg.secretName))
+		tr := TargetReport{Host: r.Host, Owner: r.Owner, Repo: r.Repo, SecretName: cfg.secretName, StartedAt: time.Now(), WriteUnverified: unverified[r]}
+		var (
+			result *usecases.RegisterResult
+			doErr  = ucErr
+		)
+		if doErr == nil {
+			result, doErr = uc.DoRegisterRepositorySecret(targetCtx, r.Owner, r.Repo, cfg.secretName, cfg.secretValue)
+		}
+		tr.complete(result, doErr)
+		trail.record(audit.ActionRegister, tr, []byte(cfg.secretValue))
+		endSpan(span, doErr)
+		out.record(ctx, r.String(), tr, doErr)
+	})
+	return trail.close(out.result(ctx, total))
+}
+
+// outcomes collects the results of the targets processed concurrently.
+type outcomes struct {
+	report      *RunReport
+	unprocessed []string
+	errs        []error
+	mux         sync.Mutex
+}
+
+// record stores the outcome of a target; it must be called at most once per target.
+func (o *outcomes) record(ctx context.Context, target string, tr TargetReport, err error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.report.Targets = append(o.report.Targets, tr)
+	if err == nil {
+		return
+	}
+	if ctx.Err() != nil && isContextError(err) {
+		o.unprocessed = append(o.unprocessed, target)
+		return
+	}
+	o.errs = append(o.errs, fmt.Errorf("%s: %w", target, err))
+}
+
+// result tells how the run over total targets ended.
+func (o *outcomes) result(ctx context.Context, total int) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && len(o.unprocessed) > 0 {
+		slices.Sort(o.unprocessed)
+		slog.WarnContext(ctx, "run interrupted before all repositories were processed", slog.Any("unprocessed", o.unprocessed))
+		return &InterruptedError{Unprocessed: o.unprocessed, Cause: ctxErr, Errs: o.errs, Total: total}
+	}
+	if len(o.errs) > 0 {
+		return &TargetsFailedError{Err: errors.Join(o.errs...), Failed: len(o.errs), Total: total}
 	}
 	return nil
 }
 
+// preflight checks every target concurrently and returns the failures keyed by the target.
+// A target passes when it is writable and has room for the secrets named by secretNames.
+// The access is checked only if checkAccess is true, whereas the room is always checked so that a plan that cannot fit fails up front.
+// The targets passed whose write access the token does not tell are returned as unverified.
+func (a *App) preflight(ctx context.Context, provider UsecaseProvider, targets []qualifiedRepo, secretNames func(qualifiedRepo) []string, checkAccess bool) (failures map[qualifiedRepo]error, unverified map[qualifiedRepo]bool) {
+	var mux sync.Mutex
+	failures = map[qualifiedRepo]error{}
+	unverified = map[qualifiedRepo]bool{}
+	fail := func(r qualifiedRepo, err error) {
+		slog.WarnContext(ctx, "preflight check failed",
+			slog.String("repo.host", r.Host),
+			slog.String("repo.owner", r.Owner),
+			slog.String("repo.name", r.Repo),
+			log.AttrError(err),
+		)
+		mux.Lock()
+		defer mux.Unlock()
+		failures[r] = err
+	}
+	if checkAccess {
+		forEachAccount(ctx, targets, provider.CheckRepositoryAccessUsecase, func(r qualifiedRepo, uc CheckRepositoryAccessUsecase, ucErr error) {
+			err := ucErr
+			var access *usecases.RepositoryAccess
+			if err == nil {
+				access, err = uc.DoCheckRepositoryAccess(ctx, r.Owner, r.Repo)
+			}
+			if err != nil {
+				fail(r, err)
+				return
+			}
+			if !access.WriteVerified {
+				slog.WarnContext(ctx, "preflight cannot verify that the token can write the secrets; only reading them was checked",
+					slog.String("repo.host", r.Host),
+					slog.String("repo.owner", r.Owner),
+					slog.String("repo.name", r.Repo),
+				)
+				mux.Lock()
+				defer mux.Unlock()
+				unverified[r] = true
+			}
+		})
+	}
+	passed := slices.DeleteFunc(slices.Clone(targets), func(r qualifiedRepo) bool {
+		_, failed := failures[r]
+		return failed
+	})
+	forEachAccount(ctx, passed, provider.CheckSecretQuotaUsecase, func(r qualifiedRepo, uc CheckSecretQuotaUsecase, ucErr error) {
+		err := ucErr
+		if err == nil {
+			err = uc.DoCheckSecretQuota(ctx, r.Owner, r.Repo, secretNames(r))
+		}
+		if err != nil {
+			fail(r, err)
+		}
+	})
+	for r := range failures {
+		delete(unverified, r)
+	}
+	return failures, unverified
+}
+
+// forEachAccount obtains the usecase for each account once and then calls fn for every target concurrently.
+// If the usecase cannot be obtained fn receives the error for each target of the account.
+func forEachAccount[U any](ctx context.Context, targets []qualifiedRepo, getUsecase func(context.Context, Account) (U, error), fn func(r qualifiedRepo, uc U, ucErr error)) {
+	type accountUsecase struct {
+		uc  U
+		err error
+	}
+	byAccount := map[Account]accountUsecase{}
+	for _, r := range targets {
+		if _, ok := byAccount[r.account()]; ok {
+			continue
+		}
+		uc, err := getUsecase(ctx, r.account())
+		byAccount[r.account()] = accountUsecase{uc: uc, err: err}
+	}
+	var wg sync.WaitGroup
+	for _, r := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			au := byAccount[r.account()]
+			fn(r, au.uc, au.err)
+		}()
+	}
+	wg.Wait()
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// qualifiedRepo is a repository on the Host, which is empty for the default host.
 type qualifiedRepo struct {
-	Owner, Repo string
+	Host, Owner, Repo string
 }
 
 var _ flag.Value = (*qualifiedRepo)(nil)
 
-func (r *qualifiedRepo) String() string { return r.Owner + "/" + r.Repo }
+func (r *qualifiedRepo) String() string {
+	if r.Host == "" {
+		return r.Owner + "/" + r.Repo
+	}
+	return r.Host + "/" + r.Owner + "/" + r.Repo
+}
+
+func (r qualifiedRepo) account() Account { return Account{Host: r.Host, Owner: r.Owner} }
+
+// normalize clears the host if it is defaultHost, so that a repository is the same whether the default host is given or not.
+func (r qualifiedRepo) normalize(defaultHost string) qualifiedRepo {
+	if strings.EqualFold(r.Host, defaultHost) {
+		r.Host = ""
+	}
+	return r
+}
+
+// normalizeRepos returns the repositories normalized and sorted, each once.
+func normalizeRepos(repos iter.Seq[qualifiedRepo], defaultHost string) []qualifiedRepo {
+	var normalized []qualifiedRepo
+	for r := range repos {
+		normalized = append(normalized, r.normalize(defaultHost))
+	}
+	slices.SortFunc(normalized, compareQualifiedRepo)
+	return slices.Compact(normalized)
+}
+
+func compareQualifiedRepo(a, b qualifiedRepo) int {
+	return cmp.Or(strings.Compare(a.Host, b.Host), strings.Compare(a.Owner, b.Owner), strings.Compare(a.Repo, b.Repo))
+}
 
 func (r *qualifiedRepo) Set(v string) error {
-	owner, repo, ok := strings.Cut(v, "/")
-	if !ok {
+	parts := strings.Split(v, "/")
+	if slices.Contains(parts, "") {
+		return &MalformedQualifiedRepoError{v}
+	}
+	switch len(parts) {
+	case 2:
+		*r = qualifiedRepo{Owner: parts[0], Repo: parts[1]}
+	case 3:
+		*r = qualifiedRepo{Host: strings.ToLower(parts[0]), Owner: parts[1], Repo: parts[2]}
+	default:
 		return &MalformedQualifiedRepoError{v}
 	}
-	*r = qualifiedRepo{Owner: owner, Repo: repo}
 	return nil
 }
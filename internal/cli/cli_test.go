@@ -1,26 +1,42 @@
 package cli_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aereal/register-github-secret/internal/assertions"
 	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.uber.org/mock/gomock"
 )
 
 func TestApp_Run(t *testing.T) {
 	testCases := []struct {
-		wantErr error
-		doMock  func(m *MockRegisterRepositorySecretUsecase)
-		name    string
-		args    []string
+		wantErr      error
+		doMock       func(m *MockRegisterRepositorySecretUsecase)
+		wantConfig   *cli.UsecaseConfig
+		providerErrs map[string]error
+		name         string
+		args         []string
 	}{
 		{
 			name: "some repos specified",
 			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
 			doMock: func(m *MockRegisterRepositorySecretUsecase) {
-				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(nil).Times(1)
-				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
 			},
 			wantErr: nil,
 		},
@@ -28,8 +44,8 @@ func TestApp_Run(t *testing.T) {
 			name: "failed to register",
 			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
 			doMock: func(m *MockRegisterRepositorySecretUsecase) {
-				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(nil).Times(1)
-				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(errFailed).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
 			},
 			wantErr: errFailed,
 		},
@@ -37,10 +53,92 @@ func TestApp_Run(t *testing.T) {
 			name: "same repos repeated",
 			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo1"},
 			doMock: func(m *MockRegisterRepositorySecretUsecase) {
-				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
 			},
 			wantErr: nil,
 		},
+		{
+			name: "max attempts specified",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-max-attempts", "5", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{MaxAttempts: 5, RequestTimeout: 30 * time.Second, UserAgent: "register-github-secret", KeyCacheTTL: 24 * time.Hour},
+		},
+		{
+			name: "request timeout specified",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-request-timeout", "5s", "-timeout", "1m", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{MaxAttempts: 3, RequestTimeout: 5 * time.Second, UserAgent: "register-github-secret", KeyCacheTTL: 24 * time.Hour},
+		},
+		{
+			name: "key cache options",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-key-cache-dir", "/tmp/keys", "-key-cache-ttl", "0", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{KeyCacheDir: "/tmp/keys", MaxAttempts: 3, RequestTimeout: 30 * time.Second, UserAgent: "register-github-secret"},
+		},
+		{
+			name:    "invalid max attempts",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-max-attempts", "0", "-repos", "aereal/repo1"},
+			wantErr: &cli.InvalidMaxAttemptsError{Value: 0},
+		},
+		{
+			name: "GitHub App",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-app-id", "123", "-app-private-key-file", "key.pem", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{AppID: 123, AppPrivateKeyFile: "key.pem", MaxAttempts: 3, RequestTimeout: 30 * time.Second, UserAgent: "register-github-secret", KeyCacheTTL: 24 * time.Hour},
+		},
+		{
+			name:    "GitHub App without private key",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-app-id", "123", "-repos", "aereal/repo1"},
+			wantErr: cli.ErrIncompleteAppCredentials,
+		},
+		{
+			name: "HTTP client options",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-ca-bundle", "ca.pem", "-client-cert", "cert.pem", "-client-key", "key.pem", "-user-agent", "my-agent", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{CABundleFile: "ca.pem", ClientCertFile: "cert.pem", ClientKeyFile: "key.pem", UserAgent: "my-agent", MaxAttempts: 3, RequestTimeout: 30 * time.Second, KeyCacheTTL: 24 * time.Hour},
+		},
+		{
+			name:    "client certificate without key",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-client-cert", "cert.pem", "-repos", "aereal/repo1"},
+			wantErr: cli.ErrIncompleteClientCertificate,
+		},
+		{
+			name: "token map",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-token-map", "tokens.json", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantConfig: &cli.UsecaseConfig{TokenMapFile: "tokens.json", MaxAttempts: 3, RequestTimeout: 30 * time.Second, UserAgent: "register-github-secret", KeyCacheTTL: 24 * time.Hour},
+		},
+		{
+			name:    "token map with token file",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-token-map", "tokens.json", "-token-file", "token", "-repos", "aereal/repo1"},
+			wantErr: &cli.ConflictingFlagsError{Flag: "token-map", Other: "token-file"},
+		},
+		{
+			name:    "token map with GitHub App",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-token-map", "tokens.json", "-app-id", "123", "-app-private-key-file", "key.pem", "-repos", "aereal/repo1"},
+			wantErr: &cli.ConflictingFlagsError{Flag: "token-map", Other: "app-id"},
+		},
+		{
+			name: "no usecase for an owner",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-preflight=false", "-continue-on-preflight-failure", "-repos", "aereal/repo1", "-repos", "other/repo2"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			providerErrs: map[string]error{"other": errFailed},
+			wantErr:      errFailed,
+		},
 		{
 			name:    "help wanted",
 			args:    []string{"app", "-help"},
@@ -51,6 +149,11 @@ func TestApp_Run(t *testing.T) {
 			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "repo1"},
 			wantErr: assertions.LiteralError(`invalid value "repo1" for flag -repos: malformed qualified repository name: "repo1"`),
 		},
+		{
+			name:    "too many path segments",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "ghe.example.com/aereal/repo1/extra"},
+			wantErr: &cli.MalformedQualifiedRepoError{Input: "ghe.example.com/aereal/repo1/extra"},
+		},
 		{
 			name:    "no repos specified",
 			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah"},
@@ -66,6 +169,38 @@ func TestApp_Run(t *testing.T) {
 			args:    []string{"app", "-secret-name", "MY_SECRET"},
 			wantErr: cli.ErrSecretValueRequired,
 		},
+		{
+			name:    "secret value too large",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", strings.Repeat("a", usecases.MaxSecretSize+1), "-repos", "aereal/repo1"},
+			wantErr: &usecases.SecretTooLargeError{Size: usecases.MaxSecretSize + 1, Limit: usecases.MaxSecretSize},
+		},
+		{
+			name:    "secret name with invalid characters",
+			args:    []string{"app", "-secret-name", "my-secret", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			wantErr: &cli.InvalidSecretNameError{Name: "my-secret", Reason: "only alphanumeric characters and underscores are allowed"},
+		},
+		{
+			name:    "secret name starting with a number",
+			args:    []string{"app", "-secret-name", "1ABC", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			wantErr: &cli.InvalidSecretNameError{Name: "1ABC", Reason: "must not start with a number"},
+		},
+		{
+			name:    "reserved secret name prefix",
+			args:    []string{"app", "-secret-name", "github_token", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			wantErr: &cli.InvalidSecretNameError{Name: "github_token", Reason: "must not start with the GITHUB_ prefix"},
+		},
+		{
+			name: "normalize secret name",
+			args: []string{"app", "-secret-name", "1my-secret.v2", "-normalize-secret-name", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "_1MY_SECRET_V2", "blah blah").Return(registered, nil).Times(1)
+			},
+		},
+		{
+			name:    "reserved prefix is not normalized",
+			args:    []string{"app", "-secret-name", "github-token", "-normalize-secret-name", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			wantErr: &cli.InvalidSecretNameError{Name: "GITHUB_TOKEN", Reason: "must not start with the GITHUB_ prefix"},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -74,14 +209,520 @@ func TestApp_Run(t *testing.T) {
 			if tc.doMock != nil {
 				tc.doMock(mockUsecase)
 			}
-			app := cli.NewApp(mockUsecase)
+			var gotConfig *cli.UsecaseConfig
+			app := cli.NewApp(func(_ context.Context, cfg cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+				gotConfig = &cfg
+				return &usecaseProvider{uc: mockUsecase, errs: tc.providerErrs}, nil
+			}, io.Discard)
 			ctx := t.Context()
 			gotErr := app.Run(ctx, tc.args)
 			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
 				t.Errorf("error (-want, +got):\n%s", diff)
 			}
+			if tc.wantConfig != nil {
+				if diff := cmp.Diff(tc.wantConfig, gotConfig); diff != "" {
+					t.Errorf("config (-want, +got):\n%s", diff)
+				}
+			}
 		})
 	}
 }
 
-var errFailed = assertions.LiteralError("failure")
+func TestApp_Run_interrupted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").
+		DoAndReturn(func(ctx context.Context, _, _, _, _ string) (*usecases.RegisterResult, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).
+		Times(1)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo3", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard)
+	gotErr := app.Run(ctx, []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2", "-repos", "aereal/repo3"})
+	var interrupted *cli.InterruptedError
+	if !errors.As(gotErr, &interrupted) {
+		t.Fatalf("expected InterruptedError but got %#v", gotErr)
+	}
+	if !errors.Is(interrupted, context.Canceled) {
+		t.Errorf("expected to be caused by context.Canceled but got %v", interrupted.Cause)
+	}
+	if diff := cmp.Diff([]string{"aereal/repo2"}, interrupted.Unprocessed); diff != "" {
+		t.Errorf("unprocessed (-want, +got):\n%s", diff)
+	}
+	if !errors.Is(gotErr, errFailed) {
+		t.Errorf("expected the failure before the interruption to be kept but got %v", gotErr)
+	}
+	if got := cli.ExitCode(gotErr); got != cli.ExitPartialFailure {
+		t.Errorf("exit code = %d; want %d", got, cli.ExitPartialFailure)
+	}
+}
+
+func TestApp_Run_outputJSON(t *testing.T) {
+	testCases := []struct {
+		doMock func(m *MockRegisterRepositorySecretUsecase)
+		want   *cli.RunReport
+		name   string
+		args   []string
+	}{
+		{
+			name: "ok",
+			args: []string{"app", "-output", "json", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
+			},
+			want: &cli.RunReport{
+				Error: &cli.ErrorReport{Type: "*cli.TargetsFailedError", Message: "failed to register the secret to 1 of 2 repositories: aereal/repo2: failure"},
+				Targets: []cli.TargetReport{
+					{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: "created", KeyID: "0xdeadbeaf", Attempts: 2},
+					{Owner: "aereal", Repo: "repo2", SecretName: "MY_SECRET", Action: "failed", Error: &cli.ErrorReport{Type: "assertions.literalError", Message: "failure"}},
+				},
+			},
+		},
+		{
+			name: "several errors wrapped",
+			args: []string{"app", "-output", "json", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				denied := &usecases.InsufficientPermissionError{Owner: "aereal", Repo: "repo1", Reason: "token lacks the repo scope"}
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(nil, fmt.Errorf("%w; %w", errFailed, denied)).Times(1)
+			},
+			want: &cli.RunReport{
+				Error: &cli.ErrorReport{Type: "*cli.TargetsFailedError", Message: "failed to register the secret to 1 of 1 repositories: aereal/repo1: failure; insufficient permission on aereal/repo1: token lacks the repo scope"},
+				Targets: []cli.TargetReport{
+					{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: "failed", Error: &cli.ErrorReport{Type: "*usecases.InsufficientPermissionError", Message: "failure; insufficient permission on aereal/repo1: token lacks the repo scope"}},
+				},
+			},
+		},
+		{
+			name: "joined errors",
+			args: []string{"app", "-output", "json", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1"},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(nil, errors.Join(fmt.Errorf("wrapped: %w", errFailed), errFailed)).Times(1)
+			},
+			want: &cli.RunReport{
+				Error: &cli.ErrorReport{Type: "*cli.TargetsFailedError", Message: "failed to register the secret to 1 of 1 repositories: aereal/repo1: wrapped: failure\nfailure"},
+				Targets: []cli.TargetReport{
+					{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", Action: "failed", Error: &cli.ErrorReport{Type: "assertions.literalError", Message: "wrapped: failure\nfailure"}},
+				},
+			},
+		},
+		{
+			name: "invalid repo",
+			args: []string{"app", "-output", "json", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "repo1"},
+			want: &cli.RunReport{
+				Error:   &cli.ErrorReport{Type: "*cli.MalformedQualifiedRepoError", Message: `invalid value "repo1" for flag -repos: malformed qualified repository name: "repo1"`},
+				Targets: []cli.TargetReport{},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+			if tc.doMock != nil {
+				tc.doMock(mockUsecase)
+			}
+			out := new(bytes.Buffer)
+			app := cli.NewApp(newStaticProvider(mockUsecase), out)
+			_ = app.Run(t.Context(), tc.args)
+			got := new(cli.RunReport)
+			if err := json.Unmarshal(out.Bytes(), got); err != nil {
+				t.Fatal(err)
+			}
+			opts := cmp.Options{
+				cmpopts.IgnoreFields(cli.RunReport{}, "StartedAt", "FinishedAt", "DurationMS"),
+				cmpopts.IgnoreFields(cli.TargetReport{}, "StartedAt", "DurationMS"),
+			}
+			if diff := cmp.Diff(tc.want, got, opts); diff != "" {
+				t.Errorf("report (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApp_Run_hosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(2)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	var (
+		mux      sync.Mutex
+		accounts []cli.Account
+	)
+	provider := &usecaseProvider{uc: mockUsecase, onRequest: func(account cli.Account) {
+		mux.Lock()
+		defer mux.Unlock()
+		accounts = append(accounts, account)
+	}}
+	out := new(bytes.Buffer)
+	app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) { return provider, nil }, out)
+	args := []string{"app", "-output", "json", "-preflight=false", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "github.com/aereal/repo1", "-repos", "GHE.example.com/aereal/repo1", "-repos", "ghe.example.com/aereal/repo2"}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatal(err)
+	}
+	wantAccounts := []cli.Account{{Owner: "aereal"}, {Host: "ghe.example.com", Owner: "aereal"}}
+	if diff := cmp.Diff(wantAccounts, accounts, cmpopts.SortSlices(func(a, b cli.Account) bool { return a.Host < b.Host })); diff != "" {
+		t.Errorf("accounts (-want, +got):\n%s", diff)
+	}
+	report := new(cli.RunReport)
+	if err := json.Unmarshal(out.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	var gotTargets []string
+	for _, tr := range report.Targets {
+		gotTargets = append(gotTargets, tr.Host+":"+tr.Owner+"/"+tr.Repo)
+	}
+	if diff := cmp.Diff([]string{":aereal/repo1", "ghe.example.com:aereal/repo1", "ghe.example.com:aereal/repo2"}, gotTargets); diff != "" {
+		t.Errorf("targets (-want, +got):\n%s", diff)
+	}
+}
+
+type usecaseProvider struct {
+	uc        cli.RegisterRepositorySecretUsecase
+	check     cli.CheckRepositoryAccessUsecase
+	quota     cli.CheckSecretQuotaUsecase
+	getKey    cli.GetRepositoryPublicKeyUsecase
+	upload    cli.UploadSealedRepositorySecretUsecase
+	listRepos cli.ListOrganizationRepositoriesUsecase
+	actor     cli.IdentifyActorUsecase
+	onRequest func(account cli.Account)
+	errs      map[string]error
+}
+
+var _ cli.UsecaseProvider = (*usecaseProvider)(nil)
+
+func (p *usecaseProvider) RegisterRepositorySecretUsecase(_ context.Context, account cli.Account) (cli.RegisterRepositorySecretUsecase, error) {
+	if p.onRequest != nil {
+		p.onRequest(account)
+	}
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	return p.uc, nil
+}
+
+func (p *usecaseProvider) CheckRepositoryAccessUsecase(_ context.Context, account cli.Account) (cli.CheckRepositoryAccessUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	if p.check == nil {
+		return allowAll{}, nil
+	}
+	return p.check, nil
+}
+
+func (p *usecaseProvider) CheckSecretQuotaUsecase(_ context.Context, account cli.Account) (cli.CheckSecretQuotaUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	if p.quota == nil {
+		return allowAll{}, nil
+	}
+	return p.quota, nil
+}
+
+func (p *usecaseProvider) GetRepositoryPublicKeyUsecase(_ context.Context, account cli.Account) (cli.GetRepositoryPublicKeyUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	return p.getKey, nil
+}
+
+func (p *usecaseProvider) UploadSealedRepositorySecretUsecase(_ context.Context, account cli.Account) (cli.UploadSealedRepositorySecretUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	return p.upload, nil
+}
+
+func (p *usecaseProvider) ListOrganizationRepositoriesUsecase(_ context.Context, account cli.Account) (cli.ListOrganizationRepositoriesUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	return p.listRepos, nil
+}
+
+func (p *usecaseProvider) IdentifyActorUsecase(_ context.Context, account cli.Account) (cli.IdentifyActorUsecase, error) {
+	if err := p.errs[account.Owner]; err != nil {
+		return nil, err
+	}
+	if p.actor == nil {
+		return staticActor("octocat"), nil
+	}
+	return p.actor, nil
+}
+
+type staticActor string
+
+func (a staticActor) DoIdentifyActor(context.Context) (string, error) { return string(a), nil }
+
+type allowAll struct{}
+
+func (allowAll) DoCheckRepositoryAccess(context.Context, string, string) (*usecases.RepositoryAccess, error) {
+	return &usecases.RepositoryAccess{WriteVerified: true}, nil
+}
+
+func (allowAll) DoCheckSecretQuota(context.Context, string, string, []string) error { return nil }
+
+func newStaticProvider(uc cli.RegisterRepositorySecretUsecase) cli.NewUsecaseProviderFunc {
+	return func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return &usecaseProvider{uc: uc}, nil
+	}
+}
+
+func TestApp_Run_preflight(t *testing.T) {
+	denied := &usecases.InsufficientPermissionError{Owner: "aereal", Repo: "repo2", Reason: "token lacks the repo scope"}
+	full := &usecases.SecretQuotaExceededError{Owner: "aereal", Repo: "repo1", Existing: 100, Adding: 1, Limit: usecases.MaxRepositorySecrets}
+	writable := &usecases.RepositoryAccess{WriteVerified: true}
+	testCases := []struct {
+		wantErr        error
+		doMock         func(m *MockRegisterRepositorySecretUsecase)
+		doCheck        func(m *MockCheckRepositoryAccessUsecase)
+		doQuota        func(m *MockCheckSecretQuotaUsecase)
+		wantState      map[string]string
+		name           string
+		args           []string
+		wantUnverified []string
+		wantCode       int
+	}{
+		{
+			name: "all passed",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(m *MockCheckRepositoryAccessUsecase) {
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(writable, nil).Times(1)
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(writable, nil).Times(1)
+			},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantCode:  cli.ExitOK,
+			wantState: map[string]string{"aereal/repo1": "created", "aereal/repo2": "created"},
+		},
+		{
+			name: "write access unverified",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(m *MockCheckRepositoryAccessUsecase) {
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(writable, nil).Times(1)
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(&usecases.RepositoryAccess{WriteVerified: false}, nil).Times(1)
+			},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantCode:       cli.ExitOK,
+			wantState:      map[string]string{"aereal/repo1": "created", "aereal/repo2": "created"},
+			wantUnverified: []string{"aereal/repo2"},
+		},
+		{
+			name: "abort before any write",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(m *MockCheckRepositoryAccessUsecase) {
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(writable, nil).Times(1)
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(nil, denied).Times(1)
+			},
+			wantErr:   denied,
+			wantCode:  cli.ExitAuth,
+			wantState: map[string]string{"aereal/repo1": "skipped", "aereal/repo2": "skipped"},
+		},
+		{
+			name: "continue on failure",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-continue-on-preflight-failure", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(m *MockCheckRepositoryAccessUsecase) {
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(writable, nil).Times(1)
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(nil, denied).Times(1)
+			},
+			doMock: func(m *MockRegisterRepositorySecretUsecase) {
+				m.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+			},
+			wantErr:   denied,
+			wantCode:  cli.ExitPartialFailure,
+			wantState: map[string]string{"aereal/repo1": "created", "aereal/repo2": "skipped"},
+		},
+		{
+			name: "no room for the secret",
+			args: []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(m *MockCheckRepositoryAccessUsecase) {
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo1").Return(writable, nil).Times(1)
+				m.EXPECT().DoCheckRepositoryAccess(gomock.Any(), "aereal", "repo2").Return(nil, denied).Times(1)
+			},
+			doQuota: func(m *MockCheckSecretQuotaUsecase) {
+				m.EXPECT().DoCheckSecretQuota(gomock.Any(), "aereal", "repo1", []string{"MY_SECRET"}).Return(full).Times(1)
+			},
+			wantErr:   full,
+			wantCode:  cli.ExitAuth,
+			wantState: map[string]string{"aereal/repo1": "skipped", "aereal/repo2": "skipped"},
+		},
+		{
+			name:    "no room for the secret without the preflight",
+			args:    []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-preflight=false", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doCheck: func(*MockCheckRepositoryAccessUsecase) {},
+			doQuota: func(m *MockCheckSecretQuotaUsecase) {
+				m.EXPECT().DoCheckSecretQuota(gomock.Any(), "aereal", "repo1", []string{"MY_SECRET"}).Return(full).Times(1)
+				m.EXPECT().DoCheckSecretQuota(gomock.Any(), "aereal", "repo2", []string{"MY_SECRET"}).Return(nil).Times(1)
+			},
+			wantErr:   full,
+			wantCode:  cli.ExitFailure,
+			wantState: map[string]string{"aereal/repo1": "skipped", "aereal/repo2": "skipped"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+			if tc.doMock != nil {
+				tc.doMock(mockUsecase)
+			}
+			mockCheck := NewMockCheckRepositoryAccessUsecase(ctrl)
+			tc.doCheck(mockCheck)
+			provider := &usecaseProvider{uc: mockUsecase, check: mockCheck}
+			if tc.doQuota != nil {
+				mockQuota := NewMockCheckSecretQuotaUsecase(ctrl)
+				tc.doQuota(mockQuota)
+				provider.quota = mockQuota
+			}
+			out := new(bytes.Buffer)
+			app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+				return provider, nil
+			}, out)
+			gotErr := app.Run(t.Context(), append(tc.args, "-output", "json"))
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got := cli.ExitCode(gotErr); got != tc.wantCode {
+				t.Errorf("exit code = %d; want %d", got, tc.wantCode)
+			}
+			report := new(cli.RunReport)
+			if err := json.Unmarshal(out.Bytes(), report); err != nil {
+				t.Fatal(err)
+			}
+			gotState := map[string]string{}
+			var gotUnverified []string
+			for _, tr := range report.Targets {
+				gotState[tr.Owner+"/"+tr.Repo] = tr.Action
+				if tr.WriteUnverified {
+					gotUnverified = append(gotUnverified, tr.Owner+"/"+tr.Repo)
+				}
+			}
+			if diff := cmp.Diff(tc.wantState, gotState); diff != "" {
+				t.Errorf("actions (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantUnverified, gotUnverified); diff != "" {
+				t.Errorf("unverified (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var (
+	errFailed  = assertions.LiteralError("failure")
+	registered = &usecases.RegisterResult{Action: usecases.SecretActionCreated, KeyID: "0xdeadbeaf", Attempts: 2}
+)
+
+func TestApp_Run_logFlags(t *testing.T) {
+	errSetup := assertions.LiteralError("open the log file: permission denied")
+	testCases := []struct {
+		wantErr    error
+		setupErr   error
+		env        map[string]string
+		wantConfig *log.Config
+		name       string
+		args       []string
+	}{
+		{
+			name:       "defaults",
+			wantConfig: &log.Config{Format: log.FormatAuto, Level: slog.LevelInfo},
+		},
+		{
+			name:       "flags",
+			args:       []string{"-log-level", "debug", "-log-format", "logfmt", "-log-file", "run.log"},
+			wantConfig: &log.Config{Format: log.FormatLogfmt, Level: slog.LevelDebug, File: "run.log"},
+		},
+		{
+			name:       "environment variables",
+			env:        map[string]string{log.EnvLevel: "WARN", log.EnvFormat: "json", log.EnvFile: "env.log"},
+			wantConfig: &log.Config{Format: log.FormatJSON, Level: slog.LevelWarn, File: "env.log"},
+		},
+		{
+			name:       "flags take precedence over environment variables",
+			env:        map[string]string{log.EnvLevel: "WARN", log.EnvFormat: "json"},
+			args:       []string{"-log-level", "error", "-log-format", "text"},
+			wantConfig: &log.Config{Format: log.FormatText, Level: slog.LevelError},
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{log.EnvFormat: "yaml"},
+			wantErr: &cli.InvalidEnvError{Name: log.EnvFormat, Value: "yaml"},
+		},
+		{
+			name:       "setup failed",
+			setupErr:   errSetup,
+			wantErr:    errSetup,
+			wantConfig: &log.Config{Format: log.FormatAuto, Level: slog.LevelInfo},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctrl := gomock.NewController(t)
+			mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+			mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).AnyTimes()
+			var gotConfig *log.Config
+			setup := func(cfg log.Config) error {
+				gotConfig = &cfg
+				return tc.setupErr
+			}
+			app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithLoggerSetup(setup))
+			args := append([]string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1"}, tc.args...)
+			gotErr := app.Run(t.Context(), args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantConfig, gotConfig); diff != "" {
+				t.Errorf("log config (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type secretRegistry []string
+
+func (r *secretRegistry) Add(values ...string) { *r = append(*r, values...) }
+
+func TestApp_Run_secretRegistry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	registry := new(secretRegistry)
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithSecretRegistry(registry))
+	if err := app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(secretRegistry{"blah blah"}, *registry); diff != "" {
+		t.Errorf("registered secrets (-want, +got):\n%s", diff)
+	}
+}
+
+func TestApp_Run_shortSecretValue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "ab").Return(registered, nil).Times(1)
+	logs := new(bytes.Buffer)
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithSecretRegistry(new(secretRegistry)))
+	if err := app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "ab", "-repos", "aereal/repo1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN msg=\"the secret value is too short to be masked wherever it appears in the logs") {
+		t.Errorf("want the warning for the short secret value:\n%s", logs)
+	}
+}
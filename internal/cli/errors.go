@@ -3,6 +3,10 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aereal/register-github-secret/internal/audit"
 )
 
 type MissingTokenError struct{}
@@ -17,12 +21,206 @@ func (SecretNameRequiredError) Error() string { return "secret name required" }
 
 var ErrSecretNameRequired SecretNameRequiredError
 
+// InvalidSecretNameError tells that GitHub would refuse the secret name.
+type InvalidSecretNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidSecretNameError) Error() string {
+	return fmt.Sprintf("invalid secret name %q: %s", e.Name, e.Reason)
+}
+
+func (e *InvalidSecretNameError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", e.Name), slog.String("reason", e.Reason))
+}
+
+func (e *InvalidSecretNameError) Is(err error) bool {
+	thatErr := new(InvalidSecretNameError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Name == thatErr.Name && e.Reason == thatErr.Reason
+}
+
 type SecretValueRequiredError struct{}
 
 func (SecretValueRequiredError) Error() string { return "secret value required" }
 
 var ErrSecretValueRequired SecretValueRequiredError
 
+type IncompleteAppCredentialsError struct{}
+
+func (IncompleteAppCredentialsError) Error() string {
+	return "both GitHub App ID and private key are required to authenticate as a GitHub App"
+}
+
+var ErrIncompleteAppCredentials IncompleteAppCredentialsError
+
+type IncompleteClientCertificateError struct{}
+
+func (IncompleteClientCertificateError) Error() string {
+	return "both client certificate and key are required for mutual TLS"
+}
+
+var ErrIncompleteClientCertificate IncompleteClientCertificateError
+
+type SealedFileRequiredError struct{}
+
+func (SealedFileRequiredError) Error() string { return "sealed secrets file required" }
+
+var ErrSealedFileRequired SealedFileRequiredError
+
+type TraceFileRequiredError struct{}
+
+func (TraceFileRequiredError) Error() string {
+	return "-trace-file is required to export the spans to a file"
+}
+
+var ErrTraceFileRequired TraceFileRequiredError
+
+// InvalidSealedFileError tells that an entry of the sealed secrets file lacks a field.
+type InvalidSealedFileError struct {
+	Path  string
+	Field string
+	Index int
+}
+
+func (e *InvalidSealedFileError) Error() string {
+	return fmt.Sprintf("%s: secrets[%d] has no %s", e.Path, e.Index, e.Field)
+}
+
+func (e *InvalidSealedFileError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path), slog.String("field", e.Field), slog.Int("index", e.Index))
+}
+
+func (e *InvalidSealedFileError) Is(err error) bool {
+	thatErr := new(InvalidSealedFileError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Path == thatErr.Path && e.Index == thatErr.Index && e.Field == thatErr.Field
+}
+
+// KeyNotInKeyringError tells that the keyring has no public key of the repository.
+type KeyNotInKeyringError struct {
+	Keyring string
+	Repo    string
+}
+
+func (e *KeyNotInKeyringError) Error() string {
+	return fmt.Sprintf("no public key of %s in the keyring %s", e.Repo, e.Keyring)
+}
+
+func (e *KeyNotInKeyringError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("keyring", e.Keyring), slog.String("repo", e.Repo))
+}
+
+type ConflictingFlagsError struct {
+	Flag  string
+	Other string
+}
+
+func (e *ConflictingFlagsError) Error() string {
+	return fmt.Sprintf("-%s cannot be used with -%s", e.Flag, e.Other)
+}
+
+func (e *ConflictingFlagsError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("flag", e.Flag), slog.String("other", e.Other))
+}
+
+func (e *ConflictingFlagsError) Is(err error) bool {
+	thatErr := new(ConflictingFlagsError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Flag == thatErr.Flag && e.Other == thatErr.Other
+}
+
+type KeyringFilesRequiredError struct{}
+
+func (KeyringFilesRequiredError) Error() string {
+	return "keys diff requires the previous and the current keyring files"
+}
+
+var ErrKeyringFilesRequired KeyringFilesRequiredError
+
+// UnknownKeysCommandError tells that the subcommand of keys is missing or unknown.
+type UnknownKeysCommandError struct {
+	Name string
+}
+
+func (e *UnknownKeysCommandError) Error() string {
+	if e.Name == "" {
+		return "keys requires a subcommand: export or diff"
+	}
+	return fmt.Sprintf("unknown keys subcommand %q: want export or diff", e.Name)
+}
+
+func (e *UnknownKeysCommandError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", e.Name))
+}
+
+func (e *UnknownKeysCommandError) Is(err error) bool {
+	thatErr := new(UnknownKeysCommandError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Name == thatErr.Name
+}
+
+// UnknownAuditCommandError tells that the subcommand of audit is missing or unknown.
+type UnknownAuditCommandError struct {
+	Name string
+}
+
+func (e *UnknownAuditCommandError) Error() string {
+	if e.Name == "" {
+		return "audit requires a subcommand: verify"
+	}
+	return fmt.Sprintf("unknown audit subcommand %q: want verify", e.Name)
+}
+
+func (e *UnknownAuditCommandError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", e.Name))
+}
+
+func (e *UnknownAuditCommandError) Is(err error) bool {
+	thatErr := new(UnknownAuditCommandError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Name == thatErr.Name
+}
+
+type AuditLogRequiredError struct{}
+
+func (AuditLogRequiredError) Error() string {
+	return "audit verify requires the audit log given as the argument or by " + audit.EnvFile
+}
+
+var ErrAuditLogRequired AuditLogRequiredError
+
+type MalformedOrganizationError struct {
+	Input string
+}
+
+func (e *MalformedOrganizationError) Error() string {
+	return fmt.Sprintf("malformed organization name: %q", e.Input)
+}
+
+func (e *MalformedOrganizationError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("input", e.Input))
+}
+
+func (e *MalformedOrganizationError) Is(err error) bool {
+	thatErr := new(MalformedOrganizationError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Input == thatErr.Input
+}
+
 type MalformedQualifiedRepoError struct {
 	Input string
 }
@@ -31,6 +229,10 @@ func (e *MalformedQualifiedRepoError) Error() string {
 	return fmt.Sprintf("malformed qualified repository name: %q", e.Input)
 }
 
+func (e *MalformedQualifiedRepoError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("input", e.Input))
+}
+
 func (e *MalformedQualifiedRepoError) Is(err error) bool {
 	thatErr := new(MalformedQualifiedRepoError)
 	if !errors.As(err, &thatErr) {
@@ -38,3 +240,132 @@ func (e *MalformedQualifiedRepoError) Is(err error) bool {
 	}
 	return e.Input == thatErr.Input
 }
+
+type InvalidMaxAttemptsError struct {
+	Value int
+}
+
+func (e *InvalidMaxAttemptsError) Error() string {
+	return fmt.Sprintf("max attempts must be positive: %d", e.Value)
+}
+
+func (e *InvalidMaxAttemptsError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("value", e.Value))
+}
+
+func (e *InvalidMaxAttemptsError) Is(err error) bool {
+	thatErr := new(InvalidMaxAttemptsError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Value == thatErr.Value
+}
+
+// InterruptedError reports the repositories left untouched because the run was cancelled or timed out,
+// together with the failures that happened before.
+type InterruptedError struct {
+	Cause       error
+	Errs        []error
+	Unprocessed []string
+	// Total is the number of the targets of the run, including the failed and unprocessed ones.
+	Total int
+}
+
+func (e *InterruptedError) Error() string {
+	msg := fmt.Sprintf("interrupted (%s); unprocessed repositories: %s", e.Cause, strings.Join(e.Unprocessed, ", "))
+	if len(e.Errs) > 0 {
+		msg += fmt.Sprintf("; failed to register the secret to %d of %d repositories: %s", len(e.Errs), e.Total, errors.Join(e.Errs...))
+	}
+	return msg
+}
+
+func (e *InterruptedError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("unprocessed", e.Unprocessed), slog.Int("failed", len(e.Errs)), slog.Int("total", e.Total))
+}
+
+func (e *InterruptedError) Unwrap() []error { return append([]error{e.Cause}, e.Errs...) }
+
+// written tells the number of the targets written before the interruption.
+func (e *InterruptedError) written() int { return e.Total - len(e.Errs) - len(e.Unprocessed) }
+
+type UnknownOutputFormatError struct {
+	Format string
+}
+
+func (e *UnknownOutputFormatError) Error() string {
+	return fmt.Sprintf("unknown output format: %q", e.Format)
+}
+
+func (e *UnknownOutputFormatError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("format", e.Format))
+}
+
+// InvalidFlagError wraps an error reported by the flag package.
+type InvalidFlagError struct {
+	Err error
+}
+
+func (e *InvalidFlagError) Error() string { return e.Err.Error() }
+
+func (e *InvalidFlagError) Unwrap() error { return e.Err }
+
+// TargetsFailedError reports that the secret could not be registered to some or all of the target repositories.
+type TargetsFailedError struct {
+	Err    error
+	Failed int
+	Total  int
+}
+
+func (e *TargetsFailedError) Error() string {
+	return fmt.Sprintf("failed to register the secret to %d of %d repositories: %s", e.Failed, e.Total, e.Err)
+}
+
+func (e *TargetsFailedError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("failed", e.Failed), slog.Int("total", e.Total))
+}
+
+func (e *TargetsFailedError) Unwrap() error { return e.Err }
+
+// PreflightError lists every repository that would fail to be written.
+type PreflightError struct {
+	Failures []error
+}
+
+func (e *PreflightError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("preflight failed for %d repositories; nothing was written: %s", len(e.Failures), strings.Join(msgs, "; "))
+}
+
+func (e *PreflightError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("failures", len(e.Failures)))
+}
+
+func (e *PreflightError) Unwrap() []error { return e.Failures }
+
+// InvalidEnvError reports an environment variable holding a value its flag would refuse.
+type InvalidEnvError struct {
+	Err   error
+	Name  string
+	Value string
+}
+
+func (e *InvalidEnvError) Error() string {
+	return fmt.Sprintf("invalid value %q for environment variable %s: %s", e.Value, e.Name, e.Err)
+}
+
+func (e *InvalidEnvError) Is(other error) bool {
+	thatErr := new(InvalidEnvError)
+	if !errors.As(other, &thatErr) {
+		return false
+	}
+	return e.Name == thatErr.Name && e.Value == thatErr.Value
+}
+
+func (e *InvalidEnvError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", e.Name), slog.String("value", e.Value))
+}
+
+func (e *InvalidEnvError) Unwrap() error { return e.Err }
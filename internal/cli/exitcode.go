@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
+)
+
+// Exit codes returned by the command so that callers can decide whether to retry.
+const (
+	ExitOK = 0
+	// ExitFailure is used for errors that fall into no other class.
+	ExitFailure = 1
+	// ExitUsage means the command line was invalid; retrying without changes will not help.
+	ExitUsage = 2
+	// ExitAuth means the credential is missing or lacks permission.
+	ExitAuth = 3
+	// ExitPartialFailure means the secret was registered to some of the repositories but not all.
+	ExitPartialFailure = 4
+	// ExitTotalFailure means the secret was registered to none of the repositories.
+	ExitTotalFailure = 5
+)
+
+// ExitCode classifies err into one of the exit codes.
+// A run that wrote to some of the targets is a partial failure whatever failed on the others,
+// so that callers retrying it do not take a permission error on one target for a bad credential.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case isUsageError(err):
+		return ExitUsage
+	}
+	if written, ok := writtenTargets(err); ok {
+		switch {
+		case written > 0:
+			return ExitPartialFailure
+		case someError(err, isAuthError):
+			return ExitAuth
+		default:
+			return ExitTotalFailure
+		}
+	}
+	if someError(err, isAuthError) {
+		return ExitAuth
+	}
+	return ExitFailure
+}
+
+// writtenTargets returns the number of the targets written before the run failed with err, if err tells it.
+func writtenTargets(err error) (int, bool) {
+	if interrupted := new(InterruptedError); errors.As(err, &interrupted) {
+		return interrupted.written(), true
+	}
+	if failed := new(TargetsFailedError); errors.As(err, &failed) {
+		return failed.Total - failed.Failed, true
+	}
+	return 0, false
+}
+
+func isUsageError(err error) bool {
+	var (
+		flagErr          *InvalidFlagError
+		conflictErr      *ConflictingFlagsError
+		secretNameErr    *InvalidSecretNameError
+		tooLargeErr      *usecases.SecretTooLargeError
+		sealedFileErr    *InvalidSealedFileError
+		malformedRepoErr *MalformedQualifiedRepoError
+		malformedOrgErr  *MalformedOrganizationError
+		keysCommandErr   *UnknownKeysCommandError
+		auditCommandErr  *UnknownAuditCommandError
+		maxAttemptsErr   *InvalidMaxAttemptsError
+		outputFormatErr  *UnknownOutputFormatError
+		envErr           *InvalidEnvError
+		keyringErr       *KeyNotInKeyringError
+		gitHubURLErr     *ghclient.InvalidGitHubURLError
+		privateKeyErr    *ghclient.InvalidPrivateKeyError
+		caBundleErr      *ghclient.InvalidCABundleError
+		credSpecErr      *ghclient.InvalidCredentialSpecError
+	)
+	return errors.As(err, &flagErr) ||
+		errors.As(err, &conflictErr) ||
+		errors.As(err, &secretNameErr) ||
+		errors.As(err, &tooLargeErr) ||
+		errors.As(err, &sealedFileErr) ||
+		errors.Is(err, ErrSealedFileRequired) ||
+		errors.Is(err, ErrTraceFileRequired) ||
+		errors.As(err, &malformedRepoErr) ||
+		errors.As(err, &malformedOrgErr) ||
+		errors.As(err, &keysCommandErr) ||
+		errors.Is(err, ErrKeyringFilesRequired) ||
+		errors.As(err, &auditCommandErr) ||
+		errors.Is(err, ErrAuditLogRequired) ||
+		errors.As(err, &maxAttemptsErr) ||
+		errors.As(err, &outputFormatErr) ||
+		errors.As(err, &envErr) ||
+		errors.As(err, &keyringErr) ||
+		errors.As(err, &gitHubURLErr) ||
+		errors.As(err, &privateKeyErr) ||
+		errors.As(err, &caBundleErr) ||
+		errors.As(err, &credSpecErr) ||
+		errors.Is(err, ErrSecretNameRequired) ||
+		errors.Is(err, ErrSecretValueRequired) ||
+		errors.Is(err, ErrIncompleteAppCredentials) ||
+		errors.Is(err, ErrIncompleteClientCertificate)
+}
+
+func isAuthError(err error) bool {
+	if errors.Is(err, ErrMissingToken) {
+		return true
+	}
+	switch err.(type) { //nolint:errorlint // someError walks the chain
+	case *usecases.InsufficientPermissionError, *ghclient.InstallationNotFoundError, *ghclient.UnmappedOwnerError:
+		return true
+	}
+	if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response != nil { //nolint:errorlint // someError walks the chain
+		switch errResp.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return true
+		}
+	}
+	return false
+}
+
+// someError reports whether pred holds for any error in the tree of err, including every branch of errors.Join.
+func someError(err error, pred func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if pred(err) {
+		return true
+	}
+	switch x := err.(type) { //nolint:errorlint // unwrapping by hand to visit every branch
+	case interface{ Unwrap() error }:
+		return someError(x.Unwrap(), pred)
+	case interface{ Unwrap() []error }:
+		for _, e := range x.Unwrap() {
+			if someError(e, pred) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestExitCode(t *testing.T) {
+	forbidden := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}}
+	serverErr := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+	testCases := []struct {
+		err  error
+		name string
+		want int
+	}{
+		{name: "no error", err: nil, want: cli.ExitOK},
+		{name: "unclassified", err: errors.New("oops"), want: cli.ExitFailure},
+		{name: "invalid flag", err: &cli.InvalidFlagError{Err: errors.New("flag provided but not defined: -x")}, want: cli.ExitUsage},
+		{name: "malformed repo", err: fmt.Errorf("invalid value: %w", &cli.MalformedQualifiedRepoError{Input: "repo1"}), want: cli.ExitUsage},
+		{name: "unknown keys subcommand", err: &cli.UnknownKeysCommandError{Name: "import"}, want: cli.ExitUsage},
+		{name: "audit log required", err: cli.ErrAuditLogRequired, want: cli.ExitUsage},
+		{name: "invalid secret name", err: &cli.InvalidSecretNameError{Name: "1ABC", Reason: "must not start with a number"}, want: cli.ExitUsage},
+		{name: "invalid environment variable", err: &cli.InvalidEnvError{Name: "REGISTER_GITHUB_SECRET_LOG_FORMAT", Value: "yaml"}, want: cli.ExitUsage},
+		{name: "trace file required", err: cli.ErrTraceFileRequired, want: cli.ExitUsage},
+		{name: "secret name required", err: cli.ErrSecretNameRequired, want: cli.ExitUsage},
+		{name: "keyring without the key", err: &cli.TargetsFailedError{Err: &cli.KeyNotInKeyringError{Keyring: "keyring.json", Repo: "a/b"}, Failed: 1, Total: 1}, want: cli.ExitUsage},
+		{name: "invalid GitHub URL", err: &ghclient.InvalidGitHubURLError{Input: "ftp://x", Reason: "scheme must be http or https"}, want: cli.ExitUsage},
+		{name: "invalid private key", err: &ghclient.InvalidPrivateKeyError{Reason: "no PEM block"}, want: cli.ExitUsage},
+		{name: "invalid CA bundle", err: &ghclient.InvalidCABundleError{Path: "ca.pem"}, want: cli.ExitUsage},
+		{name: "missing CA bundle", err: &ghclient.InvalidCABundleError{Path: "ca.pem", Err: fs.ErrNotExist}, want: cli.ExitUsage},
+		{name: "invalid credential spec", err: fmt.Errorf("load token map: %w", &ghclient.InvalidCredentialSpecError{Key: "aereal", Spec: "vault:x"}), want: cli.ExitUsage},
+		{name: "missing token", err: cli.ErrMissingToken, want: cli.ExitAuth},
+		{name: "app not installed", err: fmt.Errorf("a/b: %w", &ghclient.InstallationNotFoundError{Owner: "a"}), want: cli.ExitAuth},
+		{name: "owner not in token map", err: &ghclient.UnmappedOwnerError{Path: "tokens.json", Host: "github.com", Owner: "a"}, want: cli.ExitAuth},
+		{
+			name: "forbidden among failures",
+			err:  &cli.TargetsFailedError{Err: errors.Join(fmt.Errorf("a/b: %w", serverErr), fmt.Errorf("a/c: %w", forbidden)), Failed: 2, Total: 2},
+			want: cli.ExitAuth,
+		},
+		{
+			name: "forbidden mixed with successes",
+			err:  &cli.TargetsFailedError{Err: fmt.Errorf("a/c: %w", forbidden), Failed: 1, Total: 50},
+			want: cli.ExitPartialFailure,
+		},
+		{name: "partial failure", err: &cli.TargetsFailedError{Err: serverErr, Failed: 1, Total: 3}, want: cli.ExitPartialFailure},
+		{name: "total failure", err: &cli.TargetsFailedError{Err: serverErr, Failed: 3, Total: 3}, want: cli.ExitTotalFailure},
+		{name: "interrupted", err: &cli.InterruptedError{Cause: context.Canceled, Unprocessed: []string{"a/b"}, Total: 2}, want: cli.ExitPartialFailure},
+		{
+			name: "interrupted with nothing written",
+			err:  &cli.InterruptedError{Cause: context.Canceled, Errs: []error{fmt.Errorf("a/c: %w", serverErr)}, Unprocessed: []string{"a/b"}, Total: 2},
+			want: cli.ExitTotalFailure,
+		},
+		{
+			name: "forbidden before interrupted",
+			err:  &cli.InterruptedError{Cause: context.Canceled, Errs: []error{fmt.Errorf("a/c: %w", forbidden)}, Unprocessed: []string{"a/b"}, Total: 2},
+			want: cli.ExitAuth,
+		},
+		{
+			name: "forbidden before interrupted after a success",
+			err:  &cli.InterruptedError{Cause: context.Canceled, Errs: []error{fmt.Errorf("a/c: %w", forbidden)}, Unprocessed: []string{"a/b"}, Total: 3},
+			want: cli.ExitPartialFailure,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cli.ExitCode(tc.err); got != tc.want {
+				t.Errorf("ExitCode() = %d; want %d", got, tc.want)
+			}
+		})
+	}
+}
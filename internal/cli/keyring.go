@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// Keyring is a snapshot of the public keys of repositories, used to seal secrets without talking to GitHub.
+type Keyring struct {
+	Keys []KeyringEntry `json:"keys"`
+}
+
+type KeyringEntry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	// Host is empty for the default host.
+	Host  string `json:"host,omitempty"`
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	KeyID string `json:"key_id"`
+	Key   string `json:"key"`
+}
+
+func readKeyring(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	keyring := new(Keyring)
+	if err := json.Unmarshal(b, keyring); err != nil {
+		return nil, fmt.Errorf("parse keyring %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// lookup finds the key of r normalized with defaultHost, whether the entry has the default host or not.
+func (k *Keyring) lookup(r qualifiedRepo, defaultHost string) (*github.PublicKey, bool) {
+	for _, e := range k.Keys {
+		if e.repo().normalize(defaultHost) == r {
+			return e.publicKey(), true
+		}
+	}
+	return nil, false
+}
+
+func (e KeyringEntry) repo() qualifiedRepo {
+	return qualifiedRepo{Host: e.Host, Owner: e.Owner, Repo: e.Repo}
+}
+
+func (e KeyringEntry) publicKey() *github.PublicKey {
+	return &github.PublicKey{KeyID: &e.KeyID, Key: &e.Key}
+}
@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	set "github.com/hashicorp/go-set/v3"
+)
+
+func (a *App) runKeys(ctx context.Context, name string, args []string) error {
+	if len(args) == 0 {
+		return &UnknownKeysCommandError{}
+	}
+	switch args[0] {
+	case "export":
+		return a.runKeysExport(ctx, name+" export", args[1:])
+	case "diff":
+		return a.runKeysDiff(name+" diff", args[1:])
+	default:
+		return &UnknownKeysCommandError{Name: args[0]}
+	}
+}
+
+type keysExportConfig struct {
+	repos   *set.Set[qualifiedRepo]
+	orgs    *set.Set[Account]
+	outFile string
+	usecase UsecaseConfig
+	timeout time.Duration
+}
+
+func (a *App) runKeysExport(ctx context.Context, name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var cfg keysExportConfig
+	repos := bindReposFlag(fs)
+	var orgErr error
+	cfg.orgs = set.New[Account](0)
+	fs.Func("org", "organization whose repositories are all exported; ORG or HOST/ORG", func(s string) error {
+		account, err := parseOrganization(s)
+		if err != nil {
+			orgErr = fmt.Errorf("invalid value %q for flag -org: %w", s, err)
+			return err
+		}
+		_ = cfg.orgs.Insert(account)
+		return nil
+	})
+	fs.StringVar(&cfg.outFile, "out", "", "path to write the keyring to (defaults to stdout)")
+	bindUsecaseFlags(fs, &cfg.usecase)
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "timeout for the whole run (0 means no timeout)")
+	logs := bindLogFlags(fs)
+	traces := bindTraceFlags(fs)
+	a.bindMetricsFlag(fs)
+	err := parseFlags(fs, args, repos)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if orgErr != nil {
+		return orgErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = a.applyLogFlags(logs); err != nil {
+		return err
+	}
+	if err = a.applyTraceFlags(ctx, traces); err != nil {
+		return err
+	}
+	cfg.repos = repos.set
+	return traceRun(ctx, name, func(ctx context.Context) error { return a.exportKeys(ctx, cfg) })
+}
+
+func parseOrganization(v string) (Account, error) {
+	parts := strings.Split(v, "/")
+	if slices.Contains(parts, "") {
+		return Account{}, &MalformedOrganizationError{Input: v}
+	}
+	switch len(parts) {
+	case 1:
+		return Account{Owner: parts[0]}, nil
+	case 2:
+		return Account{Host: strings.ToLower(parts[0]), Owner: parts[1]}, nil
+	default:
+		return Account{}, &MalformedOrganizationError{Input: v}
+	}
+}
+
+// exportKeys writes the Keyring of the targets; nothing is written unless the keys of all of them are fetched.
+func (a *App) exportKeys(ctx context.Context, cfg keysExportConfig) error {
+	if err := cfg.usecase.validate(); err != nil {
+		return err
+	}
+	defaultHost, err := cfg.usecase.defaultHost()
+	if err != nil {
+		return err
+	}
+	targets := set.From(normalizeRepos(cfg.repos.Items(), defaultHost))
+	orgs := set.New[Account](cfg.orgs.Size())
+	for org := range cfg.orgs.Items() {
+		_ = orgs.Insert(org.normalize(defaultHost))
+	}
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	provider, err := a.newProvider(ctx, cfg.usecase)
+	if err != nil {
+		return err
+	}
+	for _, org := range slices.SortedFunc(orgs.Items(), compareAccount) {
+		uc, ucErr := provider.ListOrganizationRepositoriesUsecase(ctx, org)
+		if ucErr != nil {
+			return ucErr
+		}
+		names, listErr := uc.DoListOrganizationRepositories(ctx, org.Owner)
+		if listErr != nil {
+			return fmt.Errorf("%s: %w", org.String(), listErr)
+		}
+		for _, n := range names {
+			_ = targets.Insert(qualifiedRepo{Host: org.Host, Owner: org.Owner, Repo: n})
+		}
+	}
+	entries, err := fetchPublicKeys(ctx, provider, slices.SortedFunc(targets.Items(), compareQualifiedRepo))
+	if err != nil {
+		return err
+	}
+	keyring := &Keyring{Keys: make([]KeyringEntry, 0, len(entries))}
+	for _, r := range slices.SortedFunc(maps.Keys(entries), compareQualifiedRepo) {
+		keyring.Keys = append(keyring.Keys, entries[r])
+	}
+	b, err := json.MarshalIndent(keyring, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if cfg.outFile == "" {
+		_, err = a.stdout.Write(b)
+		return err
+	}
+	if err := os.WriteFile(cfg.outFile, b, 0o644); err != nil { //nolint:gosec // the public keys are not secret
+		return fmt.Errorf("write keyring: %w", err)
+	}
+	return nil
+}
+
+func (a Account) String() string {
+	if a.Host == "" {
+		return a.Owner
+	}
+	return a.Host + "/" + a.Owner
+}
+
+// normalize clears the host if it is defaultHost, so that an account is the same whether the default host is given or not.
+func (a Account) normalize(defaultHost string) Account {
+	if strings.EqualFold(a.Host, defaultHost) {
+		a.Host = ""
+	}
+	return a
+}
+
+func compareAccount(a, b Account) int {
+	return cmp.Or(strings.Compare(a.Host, b.Host), strings.Compare(a.Owner, b.Owner))
+}
+
+// KeyChangeKind tells how the key of a repository differs between two keyrings.
+type KeyChangeKind string
+
+const (
+	KeyChangeAdded   KeyChangeKind = "added"
+	KeyChangeRemoved KeyChangeKind = "removed"
+	KeyChangeRotated KeyChangeKind = "rotated"
+)
+
+// KeyChange is a repository whose key differs between the previous and the current keyrings.
+type KeyChange struct {
+	// Host is empty for the default host.
+	Host   string        `json:"host,omitempty"`
+	Owner  string        `json:"owner"`
+	Repo   string        `json:"repo"`
+	Change KeyChangeKind `json:"change"`
+	// PreviousKeyID is empty for the added ones and KeyID for the removed ones.
+	PreviousKeyID string `json:"previous_key_id,omitempty"`
+	KeyID         string `json:"key_id,omitempty"`
+}
+
+func (c KeyChange) repo() qualifiedRepo {
+	return qualifiedRepo{Host: c.Host, Owner: c.Owner, Repo: c.Repo}
+}
+
+// KeyringDiff is written by `keys diff -output json`.
+type KeyringDiff struct {
+	Changes []KeyChange `json:"changes"`
+}
+
+func (a *App) runKeysDiff(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] PREVIOUS_KEYRING CURRENT_KEYRING\n", name)
+		fs.PrintDefaults()
+	}
+	output := OutputFormatText
+	fs.Var(&output, "output", "result output format written to stdout: text or json")
+	var usecase UsecaseConfig
+	fs.StringVar(&usecase.GitHubURL, "github-url", os.Getenv("GITHUB_API_URL"), "URL of the GitHub Enterprise Server or its API the keys without a host belong to (defaults to GITHUB_API_URL, then github.com)")
+	err := parseFlags(fs, args, nil)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return ErrKeyringFilesRequired
+	}
+	previous, err := readKeyring(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	current, err := readKeyring(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	defaultHost, err := usecase.defaultHost()
+	if err != nil {
+		return err
+	}
+	diff := diffKeyrings(previous, current, defaultHost)
+	if output == OutputFormatJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return diff.writeText(a.stdout)
+}
+
+// diffKeyrings compares the keys by their ID; the keys fetched again without rotation are not changes.
+// The repositories are normalized with defaultHost so that an entry with the default host matches the one without.
+func diffKeyrings(previous, current *Keyring, defaultHost string) *KeyringDiff {
+	prevKeys := map[qualifiedRepo]KeyringEntry{}
+	for _, e := range previous.Keys {
+		prevKeys[e.repo().normalize(defaultHost)] = e
+	}
+	diff := &KeyringDiff{Changes: []KeyChange{}}
+	for _, e := range current.Keys {
+		r := e.repo().normalize(defaultHost)
+		prev, ok := prevKeys[r]
+		delete(prevKeys, r)
+		switch {
+		case !ok:
+			diff.Changes = append(diff.Changes, KeyChange{Host: r.Host, Owner: r.Owner, Repo: r.Repo, Change: KeyChangeAdded, KeyID: e.KeyID})
+		case prev.KeyID != e.KeyID:
+			diff.Changes = append(diff.Changes, KeyChange{Host: r.Host, Owner: r.Owner, Repo: r.Repo, Change: KeyChangeRotated, PreviousKeyID: prev.KeyID, KeyID: e.KeyID})
+		}
+	}
+	for r, prev := range prevKeys {
+		diff.Changes = append(diff.Changes, KeyChange{Host: r.Host, Owner: r.Owner, Repo: r.Repo, Change: KeyChangeRemoved, PreviousKeyID: prev.KeyID})
+	}
+	slices.SortFunc(diff.Changes, func(a, b KeyChange) int { return compareQualifiedRepo(a.repo(), b.repo()) })
+	return diff
+}
+
+func (d *KeyringDiff) writeText(w io.Writer) error {
+	for _, c := range d.Changes {
+		r := c.repo()
+		var err error
+		switch c.Change {
+		case KeyChangeAdded:
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Change, r.String(), c.KeyID)
+		case KeyChangeRemoved:
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Change, r.String(), c.PreviousKeyID)
+		default:
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s -> %s\n", c.Change, r.String(), c.PreviousKeyID, c.KeyID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApp_Run_keysExport(t *testing.T) {
+	testCases := []struct {
+		wantErr error
+		doMock  func(getKey *MockGetRepositoryPublicKeyUsecase, listRepos *MockListOrganizationRepositoriesUsecase)
+		name    string
+		args    []string
+		want    []cli.KeyringEntry
+	}{
+		{
+			name: "repos and organization",
+			args: []string{"app", "keys", "export", "-repos", "aereal/repo1", "-org", "ghe.example.com/myorg"},
+			doMock: func(getKey *MockGetRepositoryPublicKeyUsecase, listRepos *MockListOrganizationRepositoriesUsecase) {
+				listRepos.EXPECT().DoListOrganizationRepositories(gomock.Any(), "myorg").Return([]string{"repo2", "repo3"}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: ref("key1")}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "myorg", "repo2").Return(&github.PublicKey{KeyID: ref("0x2"), Key: ref("key2")}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "myorg", "repo3").Return(&github.PublicKey{KeyID: ref("0x3"), Key: ref("key3")}, nil).Times(1)
+			},
+			want: []cli.KeyringEntry{
+				{Owner: "aereal", Repo: "repo1", KeyID: "0x1", Key: "key1"},
+				{Host: "ghe.example.com", Owner: "myorg", Repo: "repo2", KeyID: "0x2", Key: "key2"},
+				{Host: "ghe.example.com", Owner: "myorg", Repo: "repo3", KeyID: "0x3", Key: "key3"},
+			},
+		},
+		{
+			name: "default host given",
+			args: []string{"app", "keys", "export", "-repos", "github.com/myorg/repo1", "-org", "myorg", "-org", "github.com/myorg"},
+			doMock: func(getKey *MockGetRepositoryPublicKeyUsecase, listRepos *MockListOrganizationRepositoriesUsecase) {
+				listRepos.EXPECT().DoListOrganizationRepositories(gomock.Any(), "myorg").Return([]string{"repo1", "repo2"}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "myorg", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: ref("key1")}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "myorg", "repo2").Return(&github.PublicKey{KeyID: ref("0x2"), Key: ref("key2")}, nil).Times(1)
+			},
+			want: []cli.KeyringEntry{
+				{Owner: "myorg", Repo: "repo1", KeyID: "0x1", Key: "key1"},
+				{Owner: "myorg", Repo: "repo2", KeyID: "0x2", Key: "key2"},
+			},
+		},
+		{
+			name: "failed to fetch a public key",
+			args: []string{"app", "keys", "export", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doMock: func(getKey *MockGetRepositoryPublicKeyUsecase, _ *MockListOrganizationRepositoriesUsecase) {
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: ref("key1")}, nil).Times(1)
+				getKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo2").Return(nil, errFailed).Times(1)
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "failed to list the repositories",
+			args: []string{"app", "keys", "export", "-org", "myorg"},
+			doMock: func(_ *MockGetRepositoryPublicKeyUsecase, listRepos *MockListOrganizationRepositoriesUsecase) {
+				listRepos.EXPECT().DoListOrganizationRepositories(gomock.Any(), "myorg").Return(nil, errFailed).Times(1)
+			},
+			wantErr: errFailed,
+		},
+		{
+			name:    "malformed organization",
+			args:    []string{"app", "keys", "export", "-org", "a/b/c"},
+			wantErr: &cli.MalformedOrganizationError{Input: "a/b/c"},
+		},
+		{
+			name:    "unknown subcommand",
+			args:    []string{"app", "keys", "import"},
+			wantErr: &cli.UnknownKeysCommandError{Name: "import"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockGetKey := NewMockGetRepositoryPublicKeyUsecase(ctrl)
+			mockListRepos := NewMockListOrganizationRepositoriesUsecase(ctrl)
+			if tc.doMock != nil {
+				tc.doMock(mockGetKey, mockListRepos)
+			}
+			out := new(bytes.Buffer)
+			app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+				return &usecaseProvider{getKey: mockGetKey, listRepos: mockListRepos}, nil
+			}, out)
+			gotErr := app.Run(t.Context(), tc.args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				if out.Len() > 0 {
+					t.Errorf("nothing should be written on failure but got %q", out.String())
+				}
+				return
+			}
+			var keyring cli.Keyring
+			if err := json.Unmarshal(out.Bytes(), &keyring); err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range keyring.Keys {
+				if e.FetchedAt.IsZero() {
+					t.Errorf("%s/%s has no fetched_at", e.Owner, e.Repo)
+				}
+			}
+			if diff := cmp.Diff(tc.want, keyring.Keys, cmpopts.IgnoreFields(cli.KeyringEntry{}, "FetchedAt")); diff != "" {
+				t.Errorf("keys (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApp_Run_keysExport_outFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockGetKey := NewMockGetRepositoryPublicKeyUsecase(ctrl)
+	mockGetKey.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: ref("key1")}, nil).Times(1)
+	outFile := filepath.Join(t.TempDir(), "keyring.json")
+	out := new(bytes.Buffer)
+	app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+		return &usecaseProvider{getKey: mockGetKey}, nil
+	}, out)
+	if err := app.Run(t.Context(), []string{"app", "keys", "export", "-repos", "aereal/repo1", "-out", outFile}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("nothing should be written to stdout but got %q", out.String())
+	}
+	b, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyring cli.Keyring
+	if err := json.Unmarshal(b, &keyring); err != nil {
+		t.Fatal(err)
+	}
+	want := []cli.KeyringEntry{{Owner: "aereal", Repo: "repo1", KeyID: "0x1", Key: "key1"}}
+	if diff := cmp.Diff(want, keyring.Keys, cmpopts.IgnoreFields(cli.KeyringEntry{}, "FetchedAt")); diff != "" {
+		t.Errorf("keys (-want, +got):\n%s", diff)
+	}
+}
+
+func TestApp_Run_keysDiff(t *testing.T) {
+	dir := t.TempDir()
+	fetchedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	previousFile := filepath.Join(dir, "previous.json")
+	writeJSON(t, previousFile, cli.Keyring{Keys: []cli.KeyringEntry{
+		{FetchedAt: fetchedAt, Owner: "aereal", Repo: "repo1", KeyID: "0x1", Key: "key1"},
+		{FetchedAt: fetchedAt, Owner: "aereal", Repo: "repo2", KeyID: "0x2", Key: "key2"},
+		{FetchedAt: fetchedAt, Owner: "aereal", Repo: "repo3", KeyID: "0x3", Key: "key3"},
+	}})
+	currentFile := filepath.Join(dir, "current.json")
+	writeJSON(t, currentFile, cli.Keyring{Keys: []cli.KeyringEntry{
+		// the default host is the same as no host
+		{FetchedAt: fetchedAt.Add(time.Hour), Host: "github.com", Owner: "aereal", Repo: "repo1", KeyID: "0x1", Key: "key1"},
+		{FetchedAt: fetchedAt.Add(time.Hour), Owner: "aereal", Repo: "repo2", KeyID: "0x22", Key: "key22"},
+		{FetchedAt: fetchedAt.Add(time.Hour), Host: "ghe.example.com", Owner: "aereal", Repo: "repo4", KeyID: "0x4", Key: "key4"},
+	}})
+	testCases := []struct {
+		wantErr error
+		name    string
+		want    string
+		args    []string
+	}{
+		{
+			name: "text",
+			args: []string{"app", "keys", "diff", previousFile, currentFile},
+			want: "rotated\taereal/repo2\t0x2 -> 0x22\n" +
+				"removed\taereal/repo3\t0x3\n" +
+				"added\tghe.example.com/aereal/repo4\t0x4\n",
+		},
+		{
+			name: "json",
+			args: []string{"app", "keys", "diff", "-output", "json", previousFile, currentFile},
+			want: `{
+  "changes": [
+    {
+      "owner": "aereal",
+      "repo": "repo2",
+      "change": "rotated",
+      "previous_key_id": "0x2",
+      "key_id": "0x22"
+    },
+    {
+      "owner": "aereal",
+      "repo": "repo3",
+      "change": "removed",
+      "previous_key_id": "0x3"
+    },
+    {
+      "host": "ghe.example.com",
+      "owner": "aereal",
+      "repo": "repo4",
+      "change": "added",
+      "key_id": "0x4"
+    }
+  ]
+}
+`,
+		},
+		{
+			name: "GitHub Enterprise Server as the default host",
+			args: []string{"app", "keys", "diff", "-github-url", "https://ghe.example.com", previousFile, currentFile},
+			want: "removed\taereal/repo1\t0x1\n" +
+				"rotated\taereal/repo2\t0x2 -> 0x22\n" +
+				"removed\taereal/repo3\t0x3\n" +
+				"added\taereal/repo4\t0x4\n" +
+				"added\tgithub.com/aereal/repo1\t0x1\n",
+		},
+		{
+			name: "no changes",
+			args: []string{"app", "keys", "diff", previousFile, previousFile},
+			want: "",
+		},
+		{
+			name:    "missing the current keyring",
+			args:    []string{"app", "keys", "diff", previousFile},
+			wantErr: cli.ErrKeyringFilesRequired,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			gotErr := cli.NewApp(nil, out).Run(t.Context(), tc.args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("output (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
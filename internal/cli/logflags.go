@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"flag"
+	"os"
+
+	"github.com/aereal/register-github-secret/internal/log"
+)
+
+// LoggerSetup installs the logger configured on the command line.
+type LoggerSetup func(cfg log.Config) error
+
+func WithLoggerSetup(setup LoggerSetup) AppOption {
+	return func(a *App) { a.setupLogger = setup }
+}
+
+// logFlags collects the logging options, whose defaults are taken from the environment variables.
+// The flag package cannot report an invalid default, so err keeps the one found in the environment.
+type logFlags struct {
+	err error
+	cfg log.Config
+}
+
+func bindLogFlags(fs *flag.FlagSet) *logFlags {
+	f := &logFlags{cfg: log.Config{Format: log.FormatAuto}}
+	if v, ok := os.LookupEnv(log.EnvLevel); ok {
+		if err := f.cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			f.err = &InvalidEnvError{Name: log.EnvLevel, Value: v, Err: err}
+		}
+	}
+	if v, ok := os.LookupEnv(log.EnvFormat); ok {
+		if err := f.cfg.Format.Set(v); err != nil {
+			f.err = &InvalidEnvError{Name: log.EnvFormat, Value: v, Err: err}
+		}
+	}
+	f.cfg.File = os.Getenv(log.EnvFile)
+	fs.TextVar(&f.cfg.Level, "log-level", f.cfg.Level, "minimum level of the logs: DEBUG, which also traces the GitHub API requests, INFO, WARN or ERROR (env "+log.EnvLevel+")")
+	fs.Var(&f.cfg.Format, "log-format", "log format: text, json, logfmt or auto, which is colored text on a terminal and json otherwise (env "+log.EnvFormat+")")
+	fs.StringVar(&f.cfg.File, "log-file", f.cfg.File, "path to append the logs to instead of stderr (env "+log.EnvFile+")")
+	return f
+}
+
+// applyLogFlags installs the logger configured by f unless no LoggerSetup is given.
+func (a *App) applyLogFlags(f *logFlags) error {
+	if f.err != nil {
+		return f.err
+	}
+	if a.setupLogger == nil {
+		return nil
+	}
+	return a.setupLogger(f.cfg)
+}
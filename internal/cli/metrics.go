@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/aereal/register-github-secret/internal/metrics"
+)
+
+// RunMetrics collects the metrics of a run to be written to the file given by -metrics-file.
+type RunMetrics interface {
+	CountSecret(host, owner, result string)
+	ObserveRun(command string, finishedAt time.Time, elapsed time.Duration, succeeded bool)
+	WriteFile(path string) error
+}
+
+var _ RunMetrics = (*metrics.Run)(nil)
+
+// WithRunMetrics makes the App count into m, which should be shared with the usecases to have the API calls counted too.
+func WithRunMetrics(m RunMetrics) AppOption {
+	return func(a *App) { a.metrics = m }
+}
+
+func (a *App) bindMetricsFlag(fs *flag.FlagSet) {
+	fs.StringVar(&a.metricsFile, "metrics-file", "", "path to write the metrics of the run to in the Prometheus text format, such as the directory of the node_exporter textfile collector")
+}
+
+// countSecrets counts the targets of the report by the result.
+// The targets on the default host are counted under the host resolved from cfg, so that they are told apart from the ones on other hosts.
+func (a *App) countSecrets(report *RunReport, cfg UsecaseConfig) {
+	// an invalid -github-url fails the run before any target is reported
+	defaultHost, _ := cfg.defaultHost()
+	for _, tr := range report.Targets {
+		result := metrics.ResultWritten
+		switch tr.Action {
+		case targetActionFailed:
+			result = metrics.ResultFailed
+		case targetActionSkipped:
+			result = metrics.ResultSkipped
+		}
+		host := tr.Host
+		if host == "" {
+			host = defaultHost
+		}
+		a.metrics.CountSecret(host, tr.Owner, result)
+	}
+}
+
+// writeMetrics writes the metrics of the run of the command that ended with runErr, if -metrics-file is given.
+// Failing to write them fails the run only if it succeeded otherwise.
+func (a *App) writeMetrics(ctx context.Context, command string, startedAt time.Time, runErr error) error {
+	if a.metricsFile == "" {
+		return runErr
+	}
+	finishedAt := time.Now()
+	a.metrics.ObserveRun(command, finishedAt, finishedAt.Sub(startedAt), runErr == nil)
+	err := a.metrics.WriteFile(a.metricsFile)
+	switch {
+	case err == nil:
+		return runErr
+	case runErr == nil:
+		return err
+	default:
+		slog.WarnContext(ctx, "failed to write the metrics", slog.String("path", a.metricsFile), log.AttrError(err))
+		return runErr
+	}
+}
@@ -0,0 +1,85 @@
+package cli_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/metrics"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApp_Run_metricsFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
+	path := filepath.Join(t.TempDir(), "register_github_secret.prom")
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithRunMetrics(metrics.New()))
+	_ = app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2", "-metrics-file", path})
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^register_github_secret_secrets_total\{host="github.com",owner="aereal",result="written"\} 1$`),
+		regexp.MustCompile(`(?m)^register_github_secret_secrets_total\{host="github.com",owner="aereal",result="failed"\} 1$`),
+		regexp.MustCompile(`(?m)^register_github_secret_run_success\{command="register"\} 0$`),
+		regexp.MustCompile(`(?m)^register_github_secret_run_duration_seconds\{command="register"\} \S+$`),
+	} {
+		if !want.Match(got) {
+			t.Errorf("the metrics must match %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestApp_Run_metricsFile_enterpriseHost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	path := filepath.Join(t.TempDir(), "register_github_secret.prom")
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithRunMetrics(metrics.New()))
+	if err := app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-github-url", "https://ghe.example.com/api/v3", "-metrics-file", path}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := regexp.MustCompile(`(?m)^register_github_secret_secrets_total\{host="ghe.example.com",owner="aereal",result="written"\} 1$`)
+	if !want.Match(got) {
+		t.Errorf("the metrics must match %s:\n%s", want, got)
+	}
+}
+
+func TestApp_Run_metricsFileNotWritable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "register_github_secret.prom")
+	testCases := []struct {
+		name        string
+		result      error
+		wantMessage string
+	}{
+		{name: "run succeeded", wantMessage: "write metrics:"},
+		{name: "run failed", result: errFailed, wantMessage: errFailed.Error()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+			result := registered
+			if tc.result != nil {
+				result = nil
+			}
+			mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(result, tc.result).Times(1)
+			app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard)
+			gotErr := app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-metrics-file", path})
+			if gotErr == nil || !strings.Contains(gotErr.Error(), tc.wantMessage) {
+				t.Errorf("want the error containing %q but got %v", tc.wantMessage, gotErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/usecases"
+)
+
+type OutputFormat string
+
+const (
+	OutputFormatText OutputFormat = "text"
+	OutputFormatJSON OutputFormat = "json"
+)
+
+var _ flag.Value = (*OutputFormat)(nil)
+
+func (f *OutputFormat) String() string { return string(*f) }
+
+func (f *OutputFormat) Set(v string) error {
+	switch OutputFormat(v) {
+	case OutputFormatText, OutputFormatJSON:
+		*f = OutputFormat(v)
+		return nil
+	default:
+		return &UnknownOutputFormatError{Format: v}
+	}
+}
+
+const (
+	targetActionFailed  = "failed"
+	targetActionSkipped = "skipped"
+)
+
+// RunReport is the machine-readable result of a run written by `-output json`.
+type RunReport struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Error      *ErrorReport   `json:"error,omitempty"`
+	Targets    []TargetReport `json:"targets"`
+	DurationMS int64          `json:"duration_ms"`
+}
+
+type TargetReport struct {
+	StartedAt time.Time    `json:"started_at"`
+	Error     *ErrorReport `json:"error,omitempty"`
+	// Host is empty for the default host.
+	Host       string `json:"host,omitempty"`
+	Owner      string `json:"owner"`
+	Repo       string `json:"repo"`
+	SecretName string `json:"secret_name"`
+	// Action is one of created, updated, failed or skipped.
+	Action     string `json:"action"`
+	KeyID      string `json:"key_id,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	// WriteUnverified is true if the preflight checked only that the token can read the secrets,
+	// as fine-grained tokens and GitHub App installations do not tell whether they can write them.
+	WriteUnverified bool `json:"write_unverified,omitempty"`
+}
+
+// complete fills the outcome of the write into the report.
+func (tr *TargetReport) complete(result *usecases.RegisterResult, err error) {
+	tr.DurationMS = time.Since(tr.StartedAt).Milliseconds()
+	switch {
+	case err == nil:
+		tr.Action = string(result.Action)
+		tr.KeyID = result.KeyID
+		tr.Attempts = result.Attempts
+	case isContextError(err):
+		tr.Action = targetActionSkipped
+		tr.Error = newErrorReport(err)
+	default:
+		tr.Action = targetActionFailed
+		tr.Error = newErrorReport(err)
+	}
+}
+
+type ErrorReport struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// newErrorReport describes err by its most specific type, looking through the wrappers made by fmt.Errorf and errors.Join.
+func newErrorReport(err error) *ErrorReport {
+	if err == nil {
+		return nil
+	}
+	return &ErrorReport{Type: fmt.Sprintf("%T", typedError(err)), Message: err.Error()}
+}
+
+// typedError returns the first error in the tree of err that describes itself as a slog.LogValuer, as the typed errors do,
+// or else the innermost error of the first branch.
+func typedError(err error) error {
+	if typed := findLogValuer(err); typed != nil {
+		return typed
+	}
+	for {
+		causes := unwrapErrors(err)
+		if len(causes) == 0 {
+			return err
+		}
+		err = causes[0]
+	}
+}
+
+func findLogValuer(err error) error {
+	if _, ok := err.(slog.LogValuer); ok { //nolint:errorlint // the tree is walked by hand
+		return err
+	}
+	for _, cause := range unwrapErrors(err) {
+		if typed := findLogValuer(cause); typed != nil {
+			return typed
+		}
+	}
+	return nil
+}
+
+// unwrapErrors returns the errors err wraps, which are more than one for errors.Join and fmt.Errorf with several %w.
+func unwrapErrors(err error) []error {
+	var causes []error
+	switch x := err.(type) { //nolint:errorlint // unwrapping by hand to visit every branch
+	case interface{ Unwrap() error }:
+		causes = []error{x.Unwrap()}
+	case interface{ Unwrap() []error }:
+		// cloned not to modify the errors held by err
+		causes = slices.Clone(x.Unwrap())
+	}
+	return slices.DeleteFunc(causes, func(cause error) bool { return cause == nil })
+}
+
+func (r *RunReport) finish(finishedAt time.Time, err error) {
+	r.FinishedAt = finishedAt
+	r.DurationMS = finishedAt.Sub(r.StartedAt).Milliseconds()
+	r.Error = newErrorReport(err)
+	slices.SortFunc(r.Targets, func(a, b TargetReport) int {
+		return cmp.Or(strings.Compare(a.Host, b.Host), strings.Compare(a.Owner, b.Owner), strings.Compare(a.Repo, b.Repo))
+	})
+	if r.Targets == nil {
+		r.Targets = []TargetReport{}
+	}
+}
+
+func (r *RunReport) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
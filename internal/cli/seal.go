@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/audit"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SealedDocument is written by the seal command and read by the upload command.
+// It holds no plain values, so it can be committed to a repository.
+type SealedDocument struct {
+	Secrets []SealedEntry `json:"secrets"`
+}
+
+type SealedEntry struct {
+	// Host is empty for the default host.
+	Host           string `json:"host,omitempty"`
+	Owner          string `json:"owner"`
+	Repo           string `json:"repo"`
+	SecretName     string `json:"secret_name"`
+	KeyID          string `json:"key_id"`
+	EncryptedValue string `json:"encrypted_value"`
+}
+
+func (e SealedEntry) repo() qualifiedRepo {
+	return qualifiedRepo{Host: e.Host, Owner: e.Owner, Repo: e.Repo}
+}
+
+func readSealedDocument(path string) (*SealedDocument, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sealed secrets: %w", err)
+	}
+	doc := new(SealedDocument)
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("parse sealed secrets %s: %w", path, err)
+	}
+	for i, e := range doc.Secrets {
+		for _, f := range []struct{ name, value string }{
+			{"owner", e.Owner},
+			{"repo", e.Repo},
+			{"secret_name", e.SecretName},
+			{"key_id", e.KeyID},
+			{"encrypted_value", e.EncryptedValue},
+		} {
+			if f.value == "" {
+				return nil, &InvalidSealedFileError{Path: path, Index: i, Field: f.name}
+			}
+		}
+	}
+	return doc, nil
+}
+
+type sealConfig struct {
+	repos       []qualifiedRepo
+	secretName  string
+	secretValue string
+	keyringFile string
+	usecase     UsecaseConfig
+	timeout     time.Duration
+	// normalizeSecretName rewrites the secret name into the form GitHub accepts instead of refusing it.
+	normalizeSecretName bool
+}
+
+func (a *App) runSeal(ctx context.Context, name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var cfg sealConfig
+	repos := bindReposFlag(fs)
+	fs.StringVar(&cfg.secretName, "secret-name", "", "secret name")
+	fs.StringVar(&cfg.secretValue, "secret-value", "", secretValueUsage)
+	fs.BoolVar(&cfg.normalizeSecretName, "normalize-secret-name", false, normalizeSecretNameUsage)
+	fs.StringVar(&cfg.keyringFile, "keyring", "", "path to a JSON keyring to seal offline instead of fetching the public keys")
+	bindUsecaseFlags(fs, &cfg.usecase)
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "timeout for the whole run (0 means no timeout)")
+	logs := bindLogFlags(fs)
+	traces := bindTraceFlags(fs)
+	a.bindMetricsFlag(fs)
+	err := parseFlags(fs, args, repos)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = a.applyLogFlags(logs); err != nil {
+		return err
+	}
+	if err = a.applyTraceFlags(ctx, traces); err != nil {
+		return err
+	}
+	a.registerSecret(ctx, cfg.secretValue)
+	cfg.repos = slices.SortedFunc(repos.set.Items(), compareQualifiedRepo)
+	return traceRun(ctx, name, func(ctx context.Context) error { return a.seal(ctx, cfg) })
+}
+
+// seal writes the SealedDocument for every target to stdout; nothing is written unless all of them are sealed.
+func (a *App) seal(ctx context.Context, cfg sealConfig) error {
+	var err error
+	cfg.secretName, err = resolveSecretName(ctx, cfg.secretName, cfg.normalizeSecretName)
+	if err != nil {
+		return err
+	}
+	if cfg.secretValue == "" {
+		return ErrSecretValueRequired
+	}
+	if err = usecases.CheckSecretSize([]byte(cfg.secretValue)); err != nil {
+		return err
+	}
+	if err = cfg.usecase.validate(); err != nil {
+		return err
+	}
+	defaultHost, err := cfg.usecase.defaultHost()
+	if err != nil {
+		return err
+	}
+	cfg.repos = normalizeRepos(slices.Values(cfg.repos), defaultHost)
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	keys, err := a.publicKeys(ctx, cfg, defaultHost)
+	if err != nil {
+		return err
+	}
+	doc := &SealedDocument{Secrets: make([]SealedEntry, 0, len(cfg.repos))}
+	for _, r := range cfg.repos {
+		sealed, err := usecases.SealSecret(keys[r], cfg.secretName, cfg.secretValue)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.String(), err)
+		}
+		doc.Secrets = append(doc.Secrets, SealedEntry{
+			Host:           r.Host,
+			Owner:          r.Owner,
+			Repo:           r.Repo,
+			SecretName:     sealed.Name,
+			KeyID:          sealed.KeyID,
+			EncryptedValue: sealed.EncryptedValue,
+		})
+	}
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// publicKeys returns the public key of every target, taken from the keyring if given, otherwise from GitHub.
+// The targets must be normalized with defaultHost.
+func (a *App) publicKeys(ctx context.Context, cfg sealConfig, defaultHost string) (map[qualifiedRepo]*github.PublicKey, error) {
+	keys := make(map[qualifiedRepo]*github.PublicKey, len(cfg.repos))
+	if cfg.keyringFile != "" {
+		var errs []error
+		keyring, err := readKeyring(cfg.keyringFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range cfg.repos {
+			key, ok := keyring.lookup(r, defaultHost)
+			if !ok {
+				errs = append(errs, &KeyNotInKeyringError{Keyring: cfg.keyringFile, Repo: r.String()})
+				continue
+			}
+			keys[r] = key
+		}
+		if len(errs) > 0 {
+			return nil, &TargetsFailedError{Err: errors.Join(errs...), Failed: len(errs), Total: len(cfg.repos)}
+		}
+		return keys, nil
+	}
+	provider, err := a.newProvider(ctx, cfg.usecase)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fetchPublicKeys(ctx, provider, cfg.repos)
+	if err != nil {
+		return nil, err
+	}
+	for r, e := range entries {
+		keys[r] = e.publicKey()
+	}
+	return keys, nil
+}
+
+// fetchPublicKeys fetches the public key of every target concurrently and fails unless all of them are fetched.
+func fetchPublicKeys(ctx context.Context, provider UsecaseProvider, targets []qualifiedRepo) (map[qualifiedRepo]KeyringEntry, error) {
+	var (
+		mux     sync.Mutex
+		errs    []error
+		entries = make(map[qualifiedRepo]KeyringEntry, len(targets))
+	)
+	forEachAccount(ctx, targets, provider.GetRepositoryPublicKeyUsecase, func(r qualifiedRepo, uc GetRepositoryPublicKeyUsecase, ucErr error) {
+		targetCtx, span := startTargetSpan(ctx, r)
+		var (
+			key   *github.PublicKey
+			doErr = ucErr
+		)
+		if doErr == nil {
+			key, doErr = uc.DoGetRepositoryPublicKey(targetCtx, r.Owner, r.Repo)
+		}
+		endSpan(span, doErr)
+		mux.Lock()
+		defer mux.Unlock()
+		if doErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.String(), doErr))
+			return
+		}
+		entries[r] = KeyringEntry{
+			FetchedAt: time.Now().UTC(),
+			Host:      r.Host,
+			Owner:     r.Owner,
+			Repo:      r.Repo,
+			KeyID:     key.GetKeyID(),
+			Key:       key.GetKey(),
+		}
+	})
+	if len(errs) > 0 {
+		return nil, &TargetsFailedError{Err: errors.Join(errs...), Failed: len(errs), Total: len(targets)}
+	}
+	return entries, nil
+}
+
+type uploadConfig struct {
+	sealedFile string
+	// auditLog is the path to the audit log; empty means the writes are not recorded.
+	auditLog  string
+	usecase   UsecaseConfig
+	timeout   time.Duration
+	preflight bool
+}
+
+func (a *App) runUpload(ctx context.Context, name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var (
+		cfg    uploadConfig
+		output = OutputFormatText
+	)
+	fs.StringVar(&cfg.sealedFile, "sealed", "", "path to the sealed secrets written by the seal command")
+	bindUsecaseFlags(fs, &cfg.usecase)
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "timeout for the whole run (0 means no timeout)")
+	fs.Var(&output, "output", "result output format written to stdout: text or json")
+	fs.BoolVar(&cfg.preflight, "preflight", true, "check that every repository is writable before writing any of the secrets; whether it has room for them is checked regardless")
+	bindAuditFlag(fs, &cfg.auditLog)
+	logs := bindLogFlags(fs)
+	traces := bindTraceFlags(fs)
+	a.bindMetricsFlag(fs)
+	report := &RunReport{StartedAt: time.Now()}
+	err := parseFlags(fs, args, nil)
+	if err == nil {
+		err = a.applyLogFlags(logs)
+	}
+	if err == nil {
+		err = a.applyTraceFlags(ctx, traces)
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return nil
+	case err == nil:
+		err = traceRun(ctx, name, func(ctx context.Context) error { return a.upload(ctx, report, cfg) })
+	}
+	a.countSecrets(report, cfg.usecase)
+	return a.writeReport(report, output, err)
+}
+
+// upload writes the sealed secrets as they are; the ones sealed with an outdated key are refused.
+func (a *App) upload(ctx context.Context, report *RunReport, cfg uploadConfig) error {
+	if cfg.sealedFile == "" {
+		return ErrSealedFileRequired
+	}
+	if err := cfg.usecase.validate(); err != nil {
+		return err
+	}
+	defaultHost, err := cfg.usecase.defaultHost()
+	if err != nil {
+		return err
+	}
+	doc, err := readSealedDocument(cfg.sealedFile)
+	if err != nil {
+		return err
+	}
+	byRepo := map[qualifiedRepo][]SealedEntry{}
+	for _, e := range doc.Secrets {
+		r := e.repo().normalize(defaultHost)
+		byRepo[r] = append(byRepo[r], e)
+	}
+	targets := slices.SortedFunc(maps.Keys(byRepo), compareQualifiedRepo)
+	for _, r := range targets {
+		names := make([]string, 0, len(byRepo[r]))
+		for _, e := range byRepo[r] {
+			names = append(names, e.SecretName)
+		}
+		if err = validateSecretNames(names); err != nil {
+			return fmt.Errorf("%s: %s: %w", cfg.sealedFile, r.String(), err)
+		}
+	}
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	provider, err := a.newProvider(ctx, cfg.usecase)
+	if err != nil {
+		return err
+	}
+	trail, err := openAuditTrail(ctx, provider, cfg.auditLog, targets)
+	if err != nil {
+		return err
+	}
+	failures, unverified := a.preflight(ctx, provider, targets, func(r qualifiedRepo) []string {
+		names := make([]string, 0, len(byRepo[r]))
+		for _, e := range byRepo[r] {
+			names = append(names, e.SecretName)
+		}
+		return names
+	}, cfg.preflight)
+	if len(failures) > 0 {
+		preflightErr := &PreflightError{Failures: make([]error, 0, len(failures))}
+		for _, r := range targets {
+			failure, ok := failures[r]
+			if ok {
+				preflightErr.Failures = append(preflightErr.Failures, fmt.Errorf("%s: %w", r.String(), failure))
+			}
+			for _, e := range byRepo[r] {
+				report.Targets = append(report.Targets, TargetReport{Host: r.Host, Owner: r.Owner, Repo: r.Repo, SecretName: e.SecretName, Action: targetActionSkipped, Error: newErrorReport(failure), WriteUnverified: unverified[r]})
+			}
+		}
+		return trail.close(preflightErr)
+	}
+	out := &outcomes{report: report}
+	forEachAccount(ctx, targets, provider.UploadSealedRepositorySecretUsecase, func(r qualifiedRepo, uc UploadSealedRepositorySecretUsecase, ucErr error) {
+		entries := slices.SortedFunc(slices.Values(byRepo[r]), func(a, b SealedEntry) int { return cmp.Compare(a.SecretName, b.SecretName) })
+		for _, e := range entries {
+			targetCtx, span := startTargetSpan(ctx, r, attribute.String("secret.name", e.SecretName))
+			tr := TargetReport{Host: r.Host, Owner: r.Owner, Repo: r.Repo, SecretName: e.SecretName, StartedAt: time.Now(), WriteUnverified: unverified[r]}
+			var (
+				result *usecases.RegisterResult
+				doErr  = ucErr
+			)
+			if doErr == nil {
+				sealed := &usecases.SealedSecret{Name: e.SecretName, KeyID: e.KeyID, EncryptedValue: e.EncryptedValue}
+				result, doErr = uc.DoUploadSealedRepositorySecret(targetCtx, r.Owner, r.Repo, sealed)
+			}
+			tr.complete(result, doErr)
+			// the value is known only as sealed
+			trail.record(audit.ActionUpload, tr, []byte(e.EncryptedValue))
+			endSpan(span, doErr)
+			out.record(ctx, r.String()+":"+e.SecretName, tr, doErr)
+		}
+	})
+	return trail.close(out.result(ctx, len(doc.Secrets)))
+}
@@ -0,0 +1,249 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestApp_Run_seal(t *testing.T) {
+	pub, _, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedKey := base64.StdEncoding.EncodeToString(pub[:])
+	keyringFile := filepath.Join(t.TempDir(), "keyring.json")
+	keyring := cli.Keyring{Keys: []cli.KeyringEntry{
+		{Owner: "aereal", Repo: "repo1", KeyID: "0xkeyring", Key: encodedKey},
+		{Host: "github.com", Owner: "aereal", Repo: "repo3", KeyID: "0xkeyring3", Key: encodedKey},
+	}}
+	writeJSON(t, keyringFile, keyring)
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGetRepositoryPublicKeyUsecase)
+		name    string
+		args    []string
+		want    []cli.SealedEntry
+	}{
+		{
+			name: "fetch public keys",
+			args: []string{"app", "seal", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "ghe.example.com/aereal/repo2"},
+			doMock: func(m *MockGetRepositoryPublicKeyUsecase) {
+				m.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: &encodedKey}, nil).Times(1)
+				m.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo2").Return(&github.PublicKey{KeyID: ref("0x2"), Key: &encodedKey}, nil).Times(1)
+			},
+			want: []cli.SealedEntry{
+				{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0x1"},
+				{Host: "ghe.example.com", Owner: "aereal", Repo: "repo2", SecretName: "MY_SECRET", KeyID: "0x2"},
+			},
+		},
+		{
+			name: "failed to fetch a public key",
+			args: []string{"app", "seal", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"},
+			doMock: func(m *MockGetRepositoryPublicKeyUsecase) {
+				m.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo1").Return(&github.PublicKey{KeyID: ref("0x1"), Key: &encodedKey}, nil).Times(1)
+				m.EXPECT().DoGetRepositoryPublicKey(gomock.Any(), "aereal", "repo2").Return(nil, errFailed).Times(1)
+			},
+			wantErr: errFailed,
+		},
+		{
+			name: "keyring",
+			args: []string{"app", "seal", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-keyring", keyringFile, "-repos", "aereal/repo1"},
+			want: []cli.SealedEntry{
+				{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0xkeyring"},
+			},
+		},
+		{
+			name: "keyring with the default host",
+			args: []string{"app", "seal", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-keyring", keyringFile, "-repos", "github.com/aereal/repo1", "-repos", "aereal/repo3"},
+			want: []cli.SealedEntry{
+				{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0xkeyring"},
+				{Owner: "aereal", Repo: "repo3", SecretName: "MY_SECRET", KeyID: "0xkeyring3"},
+			},
+		},
+		{
+			name:    "not in the keyring",
+			args:    []string{"app", "seal", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-keyring", keyringFile, "-repos", "aereal/repo2"},
+			wantErr: assertions.LiteralError("failed to register the secret to 1 of 1 repositories: no public key of aereal/repo2 in the keyring " + keyringFile),
+		},
+		{
+			name:    "no secret value",
+			args:    []string{"app", "seal", "-secret-name", "MY_SECRET", "-repos", "aereal/repo1"},
+			wantErr: cli.ErrSecretValueRequired,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockGetKey := NewMockGetRepositoryPublicKeyUsecase(ctrl)
+			if tc.doMock != nil {
+				tc.doMock(mockGetKey)
+			}
+			out := new(bytes.Buffer)
+			app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+				return &usecaseProvider{getKey: mockGetKey}, nil
+			}, out)
+			gotErr := app.Run(t.Context(), tc.args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				if out.Len() > 0 {
+					t.Errorf("nothing should be written on failure but got %q", out.String())
+				}
+				return
+			}
+			var doc cli.SealedDocument
+			if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range doc.Secrets {
+				if e.EncryptedValue == "" {
+					t.Errorf("%s/%s is not sealed", e.Owner, e.Repo)
+				}
+			}
+			if diff := cmp.Diff(tc.want, doc.Secrets, cmpopts.IgnoreFields(cli.SealedEntry{}, "EncryptedValue")); diff != "" {
+				t.Errorf("sealed secrets (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApp_Run_upload(t *testing.T) {
+	dir := t.TempDir()
+	sealedFile := filepath.Join(dir, "sealed.json")
+	writeJSON(t, sealedFile, cli.SealedDocument{Secrets: []cli.SealedEntry{
+		{Owner: "aereal", Repo: "repo1", SecretName: "SECRET_B", KeyID: "0x1", EncryptedValue: "c2VhbGVk"},
+		{Owner: "aereal", Repo: "repo1", SecretName: "SECRET_A", KeyID: "0x1", EncryptedValue: "c2VhbGVk"},
+		{Owner: "aereal", Repo: "repo2", SecretName: "SECRET_A", KeyID: "0xold", EncryptedValue: "c2VhbGVk"},
+	}})
+	brokenFile := filepath.Join(dir, "broken.json")
+	writeJSON(t, brokenFile, cli.SealedDocument{Secrets: []cli.SealedEntry{{Owner: "aereal", Repo: "repo1", SecretName: "SECRET_A"}}})
+	collidingFile := filepath.Join(dir, "colliding.json")
+	writeJSON(t, collidingFile, cli.SealedDocument{Secrets: []cli.SealedEntry{
+		{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0x1", EncryptedValue: "c2VhbGVk"},
+		{Owner: "aereal", Repo: "repo1", SecretName: "my_secret", KeyID: "0x1", EncryptedValue: "c2VhbGVk"},
+	}})
+	duplicateFile := filepath.Join(dir, "duplicate.json")
+	writeJSON(t, duplicateFile, cli.SealedDocument{Secrets: []cli.SealedEntry{
+		{Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0x1", EncryptedValue: "c2VhbGVk"},
+		{Host: "github.com", Owner: "aereal", Repo: "repo1", SecretName: "MY_SECRET", KeyID: "0x1", EncryptedValue: "b3RoZXI="},
+	}})
+	full := &usecases.SecretQuotaExceededError{Owner: "aereal", Repo: "repo1", Existing: 99, Adding: 2, Limit: usecases.MaxRepositorySecrets}
+	mismatch := &usecases.KeyMismatchError{Owner: "aereal", Repo: "repo2", SealedKeyID: "0xold", CurrentKeyID: "0x2"}
+	testCases := []struct {
+		wantErr   error
+		doMock    func(m *MockUploadSealedRepositorySecretUsecase)
+		doQuota   func(m *MockCheckSecretQuotaUsecase)
+		wantState map[string]string
+		name      string
+		args      []string
+	}{
+		{
+			name: "refuse outdated ones",
+			args: []string{"app", "upload", "-output", "json", "-sealed", sealedFile},
+			doMock: func(m *MockUploadSealedRepositorySecretUsecase) {
+				m.EXPECT().DoUploadSealedRepositorySecret(gomock.Any(), "aereal", "repo1", &usecases.SealedSecret{Name: "SECRET_A", KeyID: "0x1", EncryptedValue: "c2VhbGVk"}).Return(registered, nil).Times(1)
+				m.EXPECT().DoUploadSealedRepositorySecret(gomock.Any(), "aereal", "repo1", &usecases.SealedSecret{Name: "SECRET_B", KeyID: "0x1", EncryptedValue: "c2VhbGVk"}).Return(registered, nil).Times(1)
+				m.EXPECT().DoUploadSealedRepositorySecret(gomock.Any(), "aereal", "repo2", &usecases.SealedSecret{Name: "SECRET_A", KeyID: "0xold", EncryptedValue: "c2VhbGVk"}).Return(nil, mismatch).Times(1)
+			},
+			wantErr:   mismatch,
+			wantState: map[string]string{"aereal/repo1:SECRET_A": "created", "aereal/repo1:SECRET_B": "created", "aereal/repo2:SECRET_A": "failed"},
+		},
+		{
+			name: "no room for the secrets",
+			args: []string{"app", "upload", "-output", "json", "-sealed", sealedFile},
+			doQuota: func(m *MockCheckSecretQuotaUsecase) {
+				m.EXPECT().DoCheckSecretQuota(gomock.Any(), "aereal", "repo1", gomock.InAnyOrder([]string{"SECRET_A", "SECRET_B"})).Return(full).Times(1)
+				m.EXPECT().DoCheckSecretQuota(gomock.Any(), "aereal", "repo2", []string{"SECRET_A"}).Return(nil).Times(1)
+			},
+			wantErr:   full,
+			wantState: map[string]string{"aereal/repo1:SECRET_A": "skipped", "aereal/repo1:SECRET_B": "skipped", "aereal/repo2:SECRET_A": "skipped"},
+		},
+		{
+			name:      "no sealed file",
+			args:      []string{"app", "upload", "-output", "json"},
+			wantErr:   cli.ErrSealedFileRequired,
+			wantState: map[string]string{},
+		},
+		{
+			name:      "names differing only in case",
+			args:      []string{"app", "upload", "-output", "json", "-sealed", collidingFile},
+			wantErr:   &cli.InvalidSecretNameError{Name: "my_secret", Reason: "collides with MY_SECRET as the names are case-insensitive"},
+			wantState: map[string]string{},
+		},
+		{
+			name:      "same name twice",
+			args:      []string{"app", "upload", "-output", "json", "-sealed", duplicateFile},
+			wantErr:   &cli.InvalidSecretNameError{Name: "MY_SECRET", Reason: "appears more than once"},
+			wantState: map[string]string{},
+		},
+		{
+			name:      "incomplete entry",
+			args:      []string{"app", "upload", "-output", "json", "-sealed", brokenFile},
+			wantErr:   &cli.InvalidSealedFileError{Path: brokenFile, Index: 0, Field: "key_id"},
+			wantState: map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockUpload := NewMockUploadSealedRepositorySecretUsecase(ctrl)
+			if tc.doMock != nil {
+				tc.doMock(mockUpload)
+			}
+			provider := &usecaseProvider{upload: mockUpload}
+			if tc.doQuota != nil {
+				mockQuota := NewMockCheckSecretQuotaUsecase(ctrl)
+				tc.doQuota(mockQuota)
+				provider.quota = mockQuota
+			}
+			out := new(bytes.Buffer)
+			app := cli.NewApp(func(context.Context, cli.UsecaseConfig) (cli.UsecaseProvider, error) {
+				return provider, nil
+			}, out)
+			gotErr := app.Run(t.Context(), tc.args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			report := new(cli.RunReport)
+			if err := json.Unmarshal(out.Bytes(), report); err != nil {
+				t.Fatal(err)
+			}
+			gotState := map[string]string{}
+			for _, tr := range report.Targets {
+				gotState[tr.Owner+"/"+tr.Repo+":"+tr.SecretName] = tr.Action
+			}
+			if diff := cmp.Diff(tc.wantState, gotState); diff != "" {
+				t.Errorf("actions (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ref[T any](t T) *T { return &t }
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// reservedSecretNamePrefix is the prefix GitHub keeps for the secrets it provides itself.
+const reservedSecretNamePrefix = "GITHUB_"
+
+// validateSecretName checks the name against the rules GitHub enforces, so that an invalid name is refused before any API call.
+// GitHub stores the names in upper case, so the rules are applied case-insensitively.
+func validateSecretName(name string) error {
+	if name == "" {
+		return ErrSecretNameRequired
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return !isSecretNameRune(r) }) {
+		return &InvalidSecretNameError{Name: name, Reason: "only alphanumeric characters and underscores are allowed"}
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return &InvalidSecretNameError{Name: name, Reason: "must not start with a number"}
+	}
+	if strings.HasPrefix(strings.ToUpper(name), reservedSecretNamePrefix) {
+		return &InvalidSecretNameError{Name: name, Reason: "must not start with the GITHUB_ prefix"}
+	}
+	return nil
+}
+
+func isSecretNameRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// normalizeSecretName turns the name into the form GitHub stores: upper case, with the invalid characters replaced by underscores
+// and an underscore put before a leading number. The reserved prefix cannot be fixed, so such names still fail the validation.
+func normalizeSecretName(name string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if !isSecretNameRune(r) {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(name))
+	if normalized != "" && normalized[0] >= '0' && normalized[0] <= '9' {
+		normalized = "_" + normalized
+	}
+	return normalized
+}
+
+// resolveSecretName returns the name to write, normalized if asked, once it is valid.
+func resolveSecretName(ctx context.Context, name string, normalize bool) (string, error) {
+	if normalize && name != "" {
+		normalized := normalizeSecretName(name)
+		if normalized != name {
+			slog.InfoContext(ctx, "secret name normalized", slog.String("secret.given_name", name), slog.String("secret.name", normalized))
+		}
+		name = normalized
+	}
+	if err := validateSecretName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// validateSecretNames validates each of the names and refuses the repeated ones, including the ones differing only in case as GitHub would take them as one.
+// Which of the repeated values would be written last is left to the order of the writes.
+func validateSecretNames(names []string) error {
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		if err := validateSecretName(name); err != nil {
+			return err
+		}
+		folded := strings.ToUpper(name)
+		if other, ok := seen[folded]; ok {
+			if other == name {
+				return &InvalidSecretNameError{Name: name, Reason: "appears more than once"}
+			}
+			return &InvalidSecretNameError{Name: name, Reason: "collides with " + other + " as the names are case-insensitive"}
+		}
+		seen[folded] = name
+	}
+	return nil
+}
+
+const normalizeSecretNameUsage = "upper-case the secret name and replace the characters GitHub refuses instead of failing"
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/aereal/register-github-secret/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aereal/register-github-secret/internal/cli")
+
+// TracerSetup installs the tracer provider configured on the command line.
+type TracerSetup func(ctx context.Context, cfg tracing.Config) error
+
+func WithTracerSetup(setup TracerSetup) AppOption {
+	return func(a *App) { a.setupTracer = setup }
+}
+
+// traceFlags collects the tracing options, whose defaults are taken from the environment variables.
+// As with logFlags, err keeps the invalid value found in the environment.
+type traceFlags struct {
+	err error
+	cfg tracing.Config
+}
+
+func bindTraceFlags(fs *flag.FlagSet) *traceFlags {
+	f := &traceFlags{cfg: tracing.Config{Exporter: tracing.ExporterNone}}
+	if v, ok := os.LookupEnv(tracing.EnvExporter); ok {
+		if err := f.cfg.Exporter.Set(v); err != nil {
+			f.err = &InvalidEnvError{Name: tracing.EnvExporter, Value: v, Err: err}
+		}
+	}
+	f.cfg.File = os.Getenv(tracing.EnvFile)
+	fs.Var(&f.cfg.Exporter, "trace-exporter", "where the spans are sent: none, otlp, configured by the OTEL_EXPORTER_OTLP_* environment variables, or file (env "+tracing.EnvExporter+")")
+	fs.StringVar(&f.cfg.File, "trace-file", f.cfg.File, "path to append the spans to as JSON lines with -trace-exporter file (env "+tracing.EnvFile+")")
+	return f
+}
+
+// applyTraceFlags installs the tracer provider configured by f unless no TracerSetup is given.
+func (a *App) applyTraceFlags(ctx context.Context, f *traceFlags) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.cfg.Exporter == tracing.ExporterFile && f.cfg.File == "" {
+		return ErrTraceFileRequired
+	}
+	if a.setupTracer == nil {
+		return nil
+	}
+	return a.setupTracer(ctx, f.cfg)
+}
+
+// traceRun calls fn in the span of the run named after the command, which joins the trace given by the environment if any.
+func traceRun(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(tracing.ContextFromEnv(ctx), name, trace.WithAttributes(attribute.String("command.name", name)))
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// startTargetSpan starts the span of the work on a target repository; the repository is told by the attributes, not the name.
+func startTargetSpan(ctx context.Context, r qualifiedRepo, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("repo.host", r.Host),
+		attribute.String("repo.owner", r.Owner),
+		attribute.String("repo.name", r.Repo),
+	)
+	return tracer.Start(ctx, "target", trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
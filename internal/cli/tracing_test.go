@@ -0,0 +1,157 @@
+package cli_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/cli"
+	"github.com/aereal/register-github-secret/internal/tracing"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApp_Run_traceFlags(t *testing.T) {
+	testCases := []struct {
+		wantErr    error
+		env        map[string]string
+		wantConfig *tracing.Config
+		name       string
+		args       []string
+	}{
+		{
+			name:       "defaults",
+			wantConfig: &tracing.Config{Exporter: tracing.ExporterNone},
+		},
+		{
+			name:       "flags",
+			args:       []string{"-trace-exporter", "file", "-trace-file", "spans.jsonl"},
+			wantConfig: &tracing.Config{Exporter: tracing.ExporterFile, File: "spans.jsonl"},
+		},
+		{
+			name:       "environment variables",
+			env:        map[string]string{tracing.EnvExporter: "otlp"},
+			wantConfig: &tracing.Config{Exporter: tracing.ExporterOTLP},
+		},
+		{
+			name:    "no trace file",
+			args:    []string{"-trace-exporter", "file"},
+			wantErr: cli.ErrTraceFileRequired,
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{tracing.EnvExporter: "jaeger"},
+			wantErr: &cli.InvalidEnvError{Name: tracing.EnvExporter, Value: "jaeger"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctrl := gomock.NewController(t)
+			mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+			mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).AnyTimes()
+			var gotConfig *tracing.Config
+			setup := func(_ context.Context, cfg tracing.Config) error {
+				gotConfig = &cfg
+				return nil
+			}
+			app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard, cli.WithTracerSetup(setup))
+			args := append([]string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1"}, tc.args...)
+			gotErr := app.Run(t.Context(), args)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantConfig, gotConfig); diff != "" {
+				t.Errorf("trace config (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// spanRecorder records the spans of the tests; the global tracer provider is bound only once in a test binary.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+func TestApp_Run_spans(t *testing.T) {
+	recorder := spanRecorder()
+	recorded := len(recorder.Ended())
+	defaultPropagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(defaultPropagator) })
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctrl := gomock.NewController(t)
+	mockUsecase := NewMockRegisterRepositorySecretUsecase(ctrl)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo1", "MY_SECRET", "blah blah").Return(registered, nil).Times(1)
+	mockUsecase.EXPECT().DoRegisterRepositorySecret(gomock.Any(), "aereal", "repo2", "MY_SECRET", "blah blah").Return(nil, errFailed).Times(1)
+	app := cli.NewApp(newStaticProvider(mockUsecase), io.Discard)
+	_ = app.Run(t.Context(), []string{"app", "-secret-name", "MY_SECRET", "-secret-value", "blah blah", "-repos", "aereal/repo1", "-repos", "aereal/repo2"})
+
+	type span struct {
+		Attributes map[attribute.Key]string
+		TraceID    string
+		Name       string
+		Parent     string
+		Status     codes.Code
+	}
+	spans := recorder.Ended()[recorded:]
+	names := map[string]string{"00f067aa0ba902b7": "remote"}
+	for _, s := range spans {
+		names[s.SpanContext().SpanID().String()] = s.Name()
+	}
+	got := map[string]span{}
+	for _, s := range spans {
+		attrs := map[attribute.Key]string{}
+		for _, kv := range s.Attributes() {
+			attrs[kv.Key] = kv.Value.Emit()
+		}
+		got[strings.TrimSpace(s.Name()+" "+attrs["repo.name"])] = span{
+			Name:       s.Name(),
+			TraceID:    s.SpanContext().TraceID().String(),
+			Parent:     names[s.Parent().SpanID().String()],
+			Status:     s.Status().Code,
+			Attributes: attrs,
+		}
+	}
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	want := map[string]span{
+		"app": {
+			Name:       "app",
+			TraceID:    traceID,
+			Parent:     "remote",
+			Status:     codes.Error,
+			Attributes: map[attribute.Key]string{"command.name": "app"},
+		},
+		"target repo1": {
+			Name:       "target",
+			TraceID:    traceID,
+			Parent:     "app",
+			Status:     codes.Unset,
+			Attributes: map[attribute.Key]string{"repo.host": "", "repo.owner": "aereal", "repo.name": "repo1", "secret.name": "MY_SECRET"},
+		},
+		"target repo2": {
+			Name:       "target",
+			TraceID:    traceID,
+			Parent:     "app",
+			Status:     codes.Error,
+			Attributes: map[attribute.Key]string{"repo.host": "", "repo.owner": "aereal", "repo.name": "repo2", "secret.name": "MY_SECRET"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("spans (-want, +got):\n%s", diff)
+	}
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aereal/register-github-secret/internal/cli (interfaces: RegisterRepositorySecretUsecase)
+// Source: github.com/aereal/register-github-secret/internal/cli (interfaces: RegisterRepositorySecretUsecase,CheckRepositoryAccessUsecase,GetRepositoryPublicKeyUsecase,UploadSealedRepositorySecretUsecase,ListOrganizationRepositoriesUsecase,CheckSecretQuotaUsecase,IdentifyActorUsecase)
 
 // Package cli_test is a generated GoMock package.
 package cli_test
@@ -8,6 +8,8 @@ import (
 	context "context"
 	reflect "reflect"
 
+	usecases "github.com/aereal/register-github-secret/internal/usecases"
+	github "github.com/google/go-github/v69/github"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -36,11 +38,12 @@ func (m *MockRegisterRepositorySecretUsecase) EXPECT() *MockRegisterRepositorySe
 }
 
 // DoRegisterRepositorySecret mocks base method.
-func (m *MockRegisterRepositorySecretUsecase) DoRegisterRepositorySecret(ctx context.Context, repoOwner, repoName, secretName, plainMsg string) error {
+func (m *MockRegisterRepositorySecretUsecase) DoRegisterRepositorySecret(ctx context.Context, repoOwner, repoName, secretName, plainMsg string) (*usecases.RegisterResult, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DoRegisterRepositorySecret", ctx, repoOwner, repoName, secretName, plainMsg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*usecases.RegisterResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // DoRegisterRepositorySecret indicates an expected call of DoRegisterRepositorySecret.
@@ -56,19 +59,396 @@ type MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall struct {
 }
 
 // Return rewrite *gomock.Call.Return
-func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) Return(arg0 error) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) Return(arg0 *usecases.RegisterResult, arg1 error) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) Do(f func(context.Context, string, string, string, string) (*usecases.RegisterResult, error)) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) DoAndReturn(f func(context.Context, string, string, string, string) (*usecases.RegisterResult, error)) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockCheckRepositoryAccessUsecase is a mock of CheckRepositoryAccessUsecase interface.
+type MockCheckRepositoryAccessUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockCheckRepositoryAccessUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockCheckRepositoryAccessUsecaseMockRecorder is the mock recorder for MockCheckRepositoryAccessUsecase.
+type MockCheckRepositoryAccessUsecaseMockRecorder struct {
+	mock *MockCheckRepositoryAccessUsecase
+}
+
+// NewMockCheckRepositoryAccessUsecase creates a new mock instance.
+func NewMockCheckRepositoryAccessUsecase(ctrl *gomock.Controller) *MockCheckRepositoryAccessUsecase {
+	mock := &MockCheckRepositoryAccessUsecase{ctrl: ctrl}
+	mock.recorder = &MockCheckRepositoryAccessUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCheckRepositoryAccessUsecase) EXPECT() *MockCheckRepositoryAccessUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoCheckRepositoryAccess mocks base method.
+func (m *MockCheckRepositoryAccessUsecase) DoCheckRepositoryAccess(ctx context.Context, repoOwner, repoName string) (*usecases.RepositoryAccess, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoCheckRepositoryAccess", ctx, repoOwner, repoName)
+	ret0, _ := ret[0].(*usecases.RepositoryAccess)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoCheckRepositoryAccess indicates an expected call of DoCheckRepositoryAccess.
+func (mr *MockCheckRepositoryAccessUsecaseMockRecorder) DoCheckRepositoryAccess(ctx, repoOwner, repoName any) *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoCheckRepositoryAccess", reflect.TypeOf((*MockCheckRepositoryAccessUsecase)(nil).DoCheckRepositoryAccess), ctx, repoOwner, repoName)
+	return &MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall{Call: call}
+}
+
+// MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall wrap *gomock.Call
+type MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall) Return(arg0 *usecases.RepositoryAccess, arg1 error) *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall) Do(f func(context.Context, string, string) (*usecases.RepositoryAccess, error)) *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall) DoAndReturn(f func(context.Context, string, string) (*usecases.RepositoryAccess, error)) *MockCheckRepositoryAccessUsecaseDoCheckRepositoryAccessCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockGetRepositoryPublicKeyUsecase is a mock of GetRepositoryPublicKeyUsecase interface.
+type MockGetRepositoryPublicKeyUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetRepositoryPublicKeyUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockGetRepositoryPublicKeyUsecaseMockRecorder is the mock recorder for MockGetRepositoryPublicKeyUsecase.
+type MockGetRepositoryPublicKeyUsecaseMockRecorder struct {
+	mock *MockGetRepositoryPublicKeyUsecase
+}
+
+// NewMockGetRepositoryPublicKeyUsecase creates a new mock instance.
+func NewMockGetRepositoryPublicKeyUsecase(ctrl *gomock.Controller) *MockGetRepositoryPublicKeyUsecase {
+	mock := &MockGetRepositoryPublicKeyUsecase{ctrl: ctrl}
+	mock.recorder = &MockGetRepositoryPublicKeyUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetRepositoryPublicKeyUsecase) EXPECT() *MockGetRepositoryPublicKeyUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoGetRepositoryPublicKey mocks base method.
+func (m *MockGetRepositoryPublicKeyUsecase) DoGetRepositoryPublicKey(ctx context.Context, repoOwner, repoName string) (*github.PublicKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoGetRepositoryPublicKey", ctx, repoOwner, repoName)
+	ret0, _ := ret[0].(*github.PublicKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoGetRepositoryPublicKey indicates an expected call of DoGetRepositoryPublicKey.
+func (mr *MockGetRepositoryPublicKeyUsecaseMockRecorder) DoGetRepositoryPublicKey(ctx, repoOwner, repoName any) *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoGetRepositoryPublicKey", reflect.TypeOf((*MockGetRepositoryPublicKeyUsecase)(nil).DoGetRepositoryPublicKey), ctx, repoOwner, repoName)
+	return &MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall{Call: call}
+}
+
+// MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall wrap *gomock.Call
+type MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall) Return(arg0 *github.PublicKey, arg1 error) *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall) Do(f func(context.Context, string, string) (*github.PublicKey, error)) *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall) DoAndReturn(f func(context.Context, string, string) (*github.PublicKey, error)) *MockGetRepositoryPublicKeyUsecaseDoGetRepositoryPublicKeyCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockUploadSealedRepositorySecretUsecase is a mock of UploadSealedRepositorySecretUsecase interface.
+type MockUploadSealedRepositorySecretUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploadSealedRepositorySecretUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockUploadSealedRepositorySecretUsecaseMockRecorder is the mock recorder for MockUploadSealedRepositorySecretUsecase.
+type MockUploadSealedRepositorySecretUsecaseMockRecorder struct {
+	mock *MockUploadSealedRepositorySecretUsecase
+}
+
+// NewMockUploadSealedRepositorySecretUsecase creates a new mock instance.
+func NewMockUploadSealedRepositorySecretUsecase(ctrl *gomock.Controller) *MockUploadSealedRepositorySecretUsecase {
+	mock := &MockUploadSealedRepositorySecretUsecase{ctrl: ctrl}
+	mock.recorder = &MockUploadSealedRepositorySecretUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUploadSealedRepositorySecretUsecase) EXPECT() *MockUploadSealedRepositorySecretUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoUploadSealedRepositorySecret mocks base method.
+func (m *MockUploadSealedRepositorySecretUsecase) DoUploadSealedRepositorySecret(ctx context.Context, repoOwner, repoName string, sealed *usecases.SealedSecret) (*usecases.RegisterResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoUploadSealedRepositorySecret", ctx, repoOwner, repoName, sealed)
+	ret0, _ := ret[0].(*usecases.RegisterResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoUploadSealedRepositorySecret indicates an expected call of DoUploadSealedRepositorySecret.
+func (mr *MockUploadSealedRepositorySecretUsecaseMockRecorder) DoUploadSealedRepositorySecret(ctx, repoOwner, repoName, sealed any) *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoUploadSealedRepositorySecret", reflect.TypeOf((*MockUploadSealedRepositorySecretUsecase)(nil).DoUploadSealedRepositorySecret), ctx, repoOwner, repoName, sealed)
+	return &MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall{Call: call}
+}
+
+// MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall wrap *gomock.Call
+type MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall) Return(arg0 *usecases.RegisterResult, arg1 error) *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall) Do(f func(context.Context, string, string, *usecases.SealedSecret) (*usecases.RegisterResult, error)) *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall) DoAndReturn(f func(context.Context, string, string, *usecases.SealedSecret) (*usecases.RegisterResult, error)) *MockUploadSealedRepositorySecretUsecaseDoUploadSealedRepositorySecretCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockListOrganizationRepositoriesUsecase is a mock of ListOrganizationRepositoriesUsecase interface.
+type MockListOrganizationRepositoriesUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockListOrganizationRepositoriesUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockListOrganizationRepositoriesUsecaseMockRecorder is the mock recorder for MockListOrganizationRepositoriesUsecase.
+type MockListOrganizationRepositoriesUsecaseMockRecorder struct {
+	mock *MockListOrganizationRepositoriesUsecase
+}
+
+// NewMockListOrganizationRepositoriesUsecase creates a new mock instance.
+func NewMockListOrganizationRepositoriesUsecase(ctrl *gomock.Controller) *MockListOrganizationRepositoriesUsecase {
+	mock := &MockListOrganizationRepositoriesUsecase{ctrl: ctrl}
+	mock.recorder = &MockListOrganizationRepositoriesUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockListOrganizationRepositoriesUsecase) EXPECT() *MockListOrganizationRepositoriesUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoListOrganizationRepositories mocks base method.
+func (m *MockListOrganizationRepositoriesUsecase) DoListOrganizationRepositories(ctx context.Context, org string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoListOrganizationRepositories", ctx, org)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoListOrganizationRepositories indicates an expected call of DoListOrganizationRepositories.
+func (mr *MockListOrganizationRepositoriesUsecaseMockRecorder) DoListOrganizationRepositories(ctx, org any) *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoListOrganizationRepositories", reflect.TypeOf((*MockListOrganizationRepositoriesUsecase)(nil).DoListOrganizationRepositories), ctx, org)
+	return &MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall{Call: call}
+}
+
+// MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall wrap *gomock.Call
+type MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall) Return(arg0 []string, arg1 error) *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall) Do(f func(context.Context, string) ([]string, error)) *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall) DoAndReturn(f func(context.Context, string) ([]string, error)) *MockListOrganizationRepositoriesUsecaseDoListOrganizationRepositoriesCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockCheckSecretQuotaUsecase is a mock of CheckSecretQuotaUsecase interface.
+type MockCheckSecretQuotaUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockCheckSecretQuotaUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockCheckSecretQuotaUsecaseMockRecorder is the mock recorder for MockCheckSecretQuotaUsecase.
+type MockCheckSecretQuotaUsecaseMockRecorder struct {
+	mock *MockCheckSecretQuotaUsecase
+}
+
+// NewMockCheckSecretQuotaUsecase creates a new mock instance.
+func NewMockCheckSecretQuotaUsecase(ctrl *gomock.Controller) *MockCheckSecretQuotaUsecase {
+	mock := &MockCheckSecretQuotaUsecase{ctrl: ctrl}
+	mock.recorder = &MockCheckSecretQuotaUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCheckSecretQuotaUsecase) EXPECT() *MockCheckSecretQuotaUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoCheckSecretQuota mocks base method.
+func (m *MockCheckSecretQuotaUsecase) DoCheckSecretQuota(ctx context.Context, repoOwner, repoName string, secretNames []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoCheckSecretQuota", ctx, repoOwner, repoName, secretNames)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DoCheckSecretQuota indicates an expected call of DoCheckSecretQuota.
+func (mr *MockCheckSecretQuotaUsecaseMockRecorder) DoCheckSecretQuota(ctx, repoOwner, repoName, secretNames any) *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoCheckSecretQuota", reflect.TypeOf((*MockCheckSecretQuotaUsecase)(nil).DoCheckSecretQuota), ctx, repoOwner, repoName, secretNames)
+	return &MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall{Call: call}
+}
+
+// MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall wrap *gomock.Call
+type MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall) Return(arg0 error) *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall {
 	c.Call = c.Call.Return(arg0)
 	return c
 }
 
 // Do rewrite *gomock.Call.Do
-func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) Do(f func(context.Context, string, string, string, string) error) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+func (c *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall) Do(f func(context.Context, string, string, []string) error) *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall) DoAndReturn(f func(context.Context, string, string, []string) error) *MockCheckSecretQuotaUsecaseDoCheckSecretQuotaCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockIdentifyActorUsecase is a mock of IdentifyActorUsecase interface.
+type MockIdentifyActorUsecase struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdentifyActorUsecaseMockRecorder
+	isgomock struct{}
+}
+
+// MockIdentifyActorUsecaseMockRecorder is the mock recorder for MockIdentifyActorUsecase.
+type MockIdentifyActorUsecaseMockRecorder struct {
+	mock *MockIdentifyActorUsecase
+}
+
+// NewMockIdentifyActorUsecase creates a new mock instance.
+func NewMockIdentifyActorUsecase(ctrl *gomock.Controller) *MockIdentifyActorUsecase {
+	mock := &MockIdentifyActorUsecase{ctrl: ctrl}
+	mock.recorder = &MockIdentifyActorUsecaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdentifyActorUsecase) EXPECT() *MockIdentifyActorUsecaseMockRecorder {
+	return m.recorder
+}
+
+// DoIdentifyActor mocks base method.
+func (m *MockIdentifyActorUsecase) DoIdentifyActor(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoIdentifyActor", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoIdentifyActor indicates an expected call of DoIdentifyActor.
+func (mr *MockIdentifyActorUsecaseMockRecorder) DoIdentifyActor(ctx any) *MockIdentifyActorUsecaseDoIdentifyActorCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoIdentifyActor", reflect.TypeOf((*MockIdentifyActorUsecase)(nil).DoIdentifyActor), ctx)
+	return &MockIdentifyActorUsecaseDoIdentifyActorCall{Call: call}
+}
+
+// MockIdentifyActorUsecaseDoIdentifyActorCall wrap *gomock.Call
+type MockIdentifyActorUsecaseDoIdentifyActorCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockIdentifyActorUsecaseDoIdentifyActorCall) Return(arg0 string, arg1 error) *MockIdentifyActorUsecaseDoIdentifyActorCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockIdentifyActorUsecaseDoIdentifyActorCall) Do(f func(context.Context) (string, error)) *MockIdentifyActorUsecaseDoIdentifyActorCall {
 	c.Call = c.Call.Do(f)
 	return c
 }
 
 // DoAndReturn rewrite *gomock.Call.DoAndReturn
-func (c *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall) DoAndReturn(f func(context.Context, string, string, string, string) error) *MockRegisterRepositorySecretUsecaseDoRegisterRepositorySecretCall {
+func (c *MockIdentifyActorUsecaseDoIdentifyActorCall) DoAndReturn(f func(context.Context) (string, error)) *MockIdentifyActorUsecaseDoIdentifyActorCall {
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
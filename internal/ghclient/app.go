@@ -0,0 +1,185 @@
+//go:generate go tool mockgen -destination ./mock_test.go -package ghclient_test -typed -write_command_comment=false github.com/aereal/register-github-secret/internal/ghclient GHAppsService
+
+package ghclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+const (
+	// GitHub rejects JWTs that live longer than 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+	// issue tokens a little in the past to tolerate clock drift between us and GitHub.
+	clockSkew = time.Minute
+	// refresh tokens this long before they expire so that in-flight requests never carry a stale one.
+	refreshMargin = time.Minute
+)
+
+type InvalidPrivateKeyError struct {
+	Reason string
+}
+
+func (e *InvalidPrivateKeyError) Error() string {
+	return "invalid GitHub App private key: " + e.Reason
+}
+
+// ParsePrivateKey parses the PEM encoded private key downloaded from the GitHub App settings.
+func ParsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, &InvalidPrivateKeyError{Reason: "no PEM block found"}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, &InvalidPrivateKeyError{Reason: err.Error()}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, &InvalidPrivateKeyError{Reason: fmt.Sprintf("unexpected key type %T", parsed)}
+	}
+	return key, nil
+}
+
+// NewAppJWTSource returns a TokenSource that mints JWTs to authenticate as the GitHub App itself.
+func NewAppJWTSource(appID int64, key *rsa.PrivateKey) *AppJWTSource {
+	return &AppJWTSource{appID: appID, key: key, now: time.Now}
+}
+
+type AppJWTSource struct {
+	expiresAt time.Time
+	key       *rsa.PrivateKey
+	now       func() time.Time
+	token     string
+	appID     int64
+	mux       sync.Mutex
+}
+
+var _ TokenSource = (*AppJWTSource)(nil)
+
+func (s *AppJWTSource) Token(context.Context) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.now()
+	if s.token != "" && now.Add(refreshMargin).Before(s.expiresAt) {
+		return s.token, nil
+	}
+	issuedAt := now.Add(-clockSkew)
+	expiresAt := now.Add(appJWTLifetime)
+	token, err := signJWT(s.key, map[string]any{
+		"iat": issuedAt.Unix(),
+		"exp": expiresAt.Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiresAt = token, expiresAt
+	return token, nil
+}
+
+func signJWT(key *rsa.PrivateKey, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("rsa.SignPKCS1v15: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+type GHAppsService interface {
+	FindOrganizationInstallation(ctx context.Context, org string) (*github.Installation, *github.Response, error)
+	FindUserInstallation(ctx context.Context, user string) (*github.Installation, *github.Response, error)
+	CreateInstallationToken(ctx context.Context, id int64, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error)
+}
+
+type InstallationNotFoundError struct {
+	Owner string
+}
+
+func (e *InstallationNotFoundError) Error() string {
+	return fmt.Sprintf("GitHub App is not installed for %q", e.Owner)
+}
+
+func (e *InstallationNotFoundError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("owner", e.Owner))
+}
+
+// FindInstallationID looks up the installation of the app for the owner, which may be either an organization or a user.
+func FindInstallationID(ctx context.Context, apps GHAppsService, owner string) (int64, error) {
+	installation, _, err := apps.FindOrganizationInstallation(ctx, owner)
+	if isNotFound(err) {
+		installation, _, err = apps.FindUserInstallation(ctx, owner)
+	}
+	if isNotFound(err) {
+		return 0, &InstallationNotFoundError{Owner: owner}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("find installation for %q: %w", owner, err)
+	}
+	return installation.GetID(), nil
+}
+
+func isNotFound(err error) bool {
+	errResp := new(github.ErrorResponse)
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// NewInstallationTokenSource returns a TokenSource that issues installation access tokens and refreshes them before they expire.
+func NewInstallationTokenSource(apps GHAppsService, installationID int64) *InstallationTokenSource {
+	return &InstallationTokenSource{apps: apps, installationID: installationID, now: time.Now}
+}
+
+type InstallationTokenSource struct {
+	expiresAt      time.Time
+	apps           GHAppsService
+	now            func() time.Time
+	token          string
+	installationID int64
+	mux            sync.Mutex
+}
+
+var _ TokenSource = (*InstallationTokenSource)(nil)
+
+func (s *InstallationTokenSource) Token(ctx context.Context) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.token != "" && s.now().Add(refreshMargin).Before(s.expiresAt) {
+		return s.token, nil
+	}
+	token, _, err := s.apps.CreateInstallationToken(ctx, s.installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("CreateInstallationToken: %w", err)
+	}
+	s.token, s.expiresAt = token.GetToken(), token.GetExpiresAt().Time
+	return s.token, nil
+}
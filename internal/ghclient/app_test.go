@@ -0,0 +1,184 @@
+package ghclient_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key := generateKey(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		wantErr error
+		name    string
+		input   []byte
+	}{
+		{name: "PKCS#1", input: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})},
+		{name: "PKCS#8", input: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		{name: "not PEM", input: []byte("blah"), wantErr: assertions.LiteralError("invalid GitHub App private key: no PEM block found")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotErr := ghclient.ParsePrivateKey(tc.input)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if tc.wantErr == nil && !got.Equal(key) {
+				t.Error("parsed key differs from the original")
+			}
+		})
+	}
+}
+
+func TestAppJWTSource_Token(t *testing.T) {
+	key := generateKey(t)
+	src := ghclient.NewAppJWTSource(12345, key)
+	token, err := src.Token(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT: %q", token)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		Issuer    string `json:"iss"`
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != "12345" {
+		t.Errorf("iss = %q", claims.Issuer)
+	}
+	if lifetime := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; lifetime > 10*time.Minute {
+		t.Errorf("JWT lives too long: %s", lifetime)
+	}
+	again, err := src.Token(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != token {
+		t.Error("expected the JWT to be reused while it is fresh")
+	}
+}
+
+func TestInstallationTokenSource_Token(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	apps := NewMockGHAppsService(ctrl)
+	gomock.InOrder(
+		apps.EXPECT().CreateInstallationToken(gomock.Any(), int64(42), nil).
+			Return(&github.InstallationToken{Token: ref("almost-expired"), ExpiresAt: &github.Timestamp{Time: time.Now().Add(30 * time.Second)}}, &github.Response{}, nil),
+		apps.EXPECT().CreateInstallationToken(gomock.Any(), int64(42), nil).
+			Return(&github.InstallationToken{Token: ref("fresh"), ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)}}, &github.Response{}, nil),
+	)
+	src := ghclient.NewInstallationTokenSource(apps, 42)
+	for _, want := range []string{"almost-expired", "fresh", "fresh"} {
+		got, err := src.Token(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Token() = %q; want %q", got, want)
+		}
+	}
+}
+
+func TestFindInstallationID(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	errOops := errors.New("oops")
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGHAppsService)
+		name    string
+		want    int64
+	}{
+		{
+			name: "organization",
+			doMock: func(m *MockGHAppsService) {
+				m.EXPECT().FindOrganizationInstallation(gomock.Any(), "aereal").Return(&github.Installation{ID: ref(int64(1))}, &github.Response{}, nil)
+			},
+			want: 1,
+		},
+		{
+			name: "user",
+			doMock: func(m *MockGHAppsService) {
+				m.EXPECT().FindOrganizationInstallation(gomock.Any(), "aereal").Return(nil, &github.Response{}, notFound)
+				m.EXPECT().FindUserInstallation(gomock.Any(), "aereal").Return(&github.Installation{ID: ref(int64(2))}, &github.Response{}, nil)
+			},
+			want: 2,
+		},
+		{
+			name: "not installed",
+			doMock: func(m *MockGHAppsService) {
+				m.EXPECT().FindOrganizationInstallation(gomock.Any(), "aereal").Return(nil, &github.Response{}, notFound)
+				m.EXPECT().FindUserInstallation(gomock.Any(), "aereal").Return(nil, &github.Response{}, notFound)
+			},
+			wantErr: assertions.LiteralError(`GitHub App is not installed for "aereal"`),
+		},
+		{
+			name: "other failure",
+			doMock: func(m *MockGHAppsService) {
+				m.EXPECT().FindOrganizationInstallation(gomock.Any(), "aereal").Return(nil, &github.Response{}, errOops)
+			},
+			wantErr: errOops,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			apps := NewMockGHAppsService(ctrl)
+			tc.doMock(apps)
+			got, gotErr := ghclient.FindInstallationID(t.Context(), apps, "aereal")
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("FindInstallationID() = %d; want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ref[T any](t T) *T { return &t }
@@ -0,0 +1,200 @@
+package ghclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialSource looks up a token from one place. Lookup returns an empty string if the source has no token.
+type CredentialSource interface {
+	Name() string
+	Lookup(ctx context.Context) (string, error)
+}
+
+type NoCredentialError struct{}
+
+func (NoCredentialError) Error() string { return "no GitHub token found in any credential source" }
+
+var ErrNoCredential NoCredentialError
+
+// ResolveToken returns the token from the first source that yields one.
+func ResolveToken(ctx context.Context, sources ...CredentialSource) (string, error) {
+	for _, src := range sources {
+		token, err := src.Lookup(ctx)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", src.Name(), err)
+		}
+		if token == "" {
+			continue
+		}
+		slog.InfoContext(ctx, "use GitHub token", slog.String("credential.source", src.Name()))
+		return token, nil
+	}
+	return "", ErrNoCredential
+}
+
+// DefaultCredentialSources returns the credential chain in order of precedence.
+// tokenFile is skipped when empty.
+func DefaultCredentialSources(tokenFile, host string) []CredentialSource {
+	var sources []CredentialSource
+	if tokenFile != "" {
+		sources = append(sources, FileCredential(tokenFile))
+	}
+	return append(sources,
+		EnvCredential("GH_TOKEN"),
+		EnvCredential("GITHUB_TOKEN"),
+		&GHCLIHostsCredential{Path: DefaultGHHostsPath(), Host: host},
+		&GitCredentialHelper{Host: host},
+	)
+}
+
+// FileCredential reads the token from the file; unlike the other sources a missing file is an error because the user asked for it explicitly.
+type FileCredential string
+
+var _ CredentialSource = FileCredential("")
+
+func (c FileCredential) Name() string { return "file:" + string(c) }
+
+func (c FileCredential) Lookup(context.Context) (string, error) {
+	b, err := os.ReadFile(string(c))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// EnvCredential reads the token from the environment variable.
+type EnvCredential string
+
+var _ CredentialSource = EnvCredential("")
+
+func (c EnvCredential) Name() string { return "env:" + string(c) }
+
+func (c EnvCredential) Lookup(context.Context) (string, error) {
+	return os.Getenv(string(c)), nil
+}
+
+// GHCLIHostsCredential reads the token stored by the gh CLI in its hosts.yml.
+type GHCLIHostsCredential struct {
+	Path string
+	Host string
+}
+
+var _ CredentialSource = (*GHCLIHostsCredential)(nil)
+
+func (c *GHCLIHostsCredential) Name() string { return "gh:" + c.Path }
+
+func (c *GHCLIHostsCredential) Lookup(context.Context) (string, error) {
+	if c.Path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(c.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return parseGHHostsToken(b, c.Host), nil
+}
+
+// parseGHHostsToken picks `oauth_token` directly under the host in hosts.yml.
+// hosts.yml is a plain two-level mapping so a full YAML parser is not needed.
+func parseGHHostsToken(b []byte, host string) string {
+	var (
+		inHost      bool
+		childIndent = -1
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == 0 {
+			key, _, _ := strings.Cut(trimmed, ":")
+			inHost = unquote(key) == host
+			childIndent = -1
+			continue
+		}
+		if !inHost {
+			continue
+		}
+		if childIndent < 0 {
+			childIndent = indent
+		}
+		if indent != childIndent {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if ok && key == "oauth_token" {
+			return unquote(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// DefaultGHHostsPath returns where the gh CLI stores hosts.yml, or an empty string if the home directory is unknown.
+func DefaultGHHostsPath() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// GitCredentialHelper asks the configured git credential helpers via `git credential fill`.
+type GitCredentialHelper struct {
+	Host string
+}
+
+var _ CredentialSource = (*GitCredentialHelper)(nil)
+
+func (c *GitCredentialHelper) Name() string { return "git-credential" }
+
+func (c *GitCredentialHelper) Lookup(ctx context.Context) (string, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return "", nil //nolint:nilerr // no git, no helper
+	}
+	cmd := exec.CommandContext(ctx, gitPath, "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + c.Host + "\n\n")
+	// never fall back to an interactive prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	out, err := cmd.Output()
+	if err != nil {
+		// git exits non-zero when no helper knows the host
+		return "", nil //nolint:nilerr
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if password, ok := strings.CutPrefix(scanner.Text(), "password="); ok {
+			return password, nil
+		}
+	}
+	return "", nil
+}
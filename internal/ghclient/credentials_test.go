@@ -0,0 +1,110 @@
+package ghclient_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+)
+
+func TestResolveToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hostsFile := filepath.Join(dir, "hosts.yml")
+	hosts := `ghe.example.com:
+    oauth_token: from-ghe
+github.com:
+    users:
+        aereal:
+            oauth_token: nested
+    oauth_token: "from-gh"
+    user: aereal
+`
+	if err := os.WriteFile(hostsFile, []byte(hosts), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		wantErr error
+		env     map[string]string
+		name    string
+		want    string
+		sources []ghclient.CredentialSource
+	}{
+		{
+			name:    "file first",
+			env:     map[string]string{"TEST_GH_TOKEN": "from-env"},
+			sources: []ghclient.CredentialSource{ghclient.FileCredential(tokenFile), ghclient.EnvCredential("TEST_GH_TOKEN")},
+			want:    "from-file",
+		},
+		{
+			name:    "skip empty env",
+			env:     map[string]string{"TEST_GH_TOKEN": "", "TEST_GITHUB_TOKEN": "from-env"},
+			sources: []ghclient.CredentialSource{ghclient.EnvCredential("TEST_GH_TOKEN"), ghclient.EnvCredential("TEST_GITHUB_TOKEN")},
+			want:    "from-env",
+		},
+		{
+			name:    "gh CLI hosts.yml",
+			sources: []ghclient.CredentialSource{&ghclient.GHCLIHostsCredential{Path: hostsFile, Host: "github.com"}},
+			want:    "from-gh",
+		},
+		{
+			name:    "gh CLI hosts.yml; other host",
+			sources: []ghclient.CredentialSource{&ghclient.GHCLIHostsCredential{Path: hostsFile, Host: "ghe.example.com"}},
+			want:    "from-ghe",
+		},
+		{
+			name:    "gh CLI hosts.yml missing",
+			sources: []ghclient.CredentialSource{&ghclient.GHCLIHostsCredential{Path: filepath.Join(dir, "nonexistent.yml"), Host: "github.com"}},
+			wantErr: ghclient.ErrNoCredential,
+		},
+		{
+			name:    "token file missing",
+			sources: []ghclient.CredentialSource{ghclient.FileCredential(filepath.Join(dir, "nonexistent"))},
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name:    "nothing found",
+			env:     map[string]string{"TEST_GH_TOKEN": ""},
+			sources: []ghclient.CredentialSource{ghclient.EnvCredential("TEST_GH_TOKEN")},
+			wantErr: ghclient.ErrNoCredential,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			got, gotErr := ghclient.ResolveToken(t.Context(), tc.sources...)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("token = %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGitCredentialHelper_Lookup(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "credential.helper")
+	t.Setenv("GIT_CONFIG_VALUE_0", "!f() { test \"$1\" = get && echo username=x-access-token && echo password=from-git; }; f")
+	got, err := (&ghclient.GitCredentialHelper{Host: "github.com"}).Lookup(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "from-git" {
+		t.Errorf("token = %q; want %q", got, "from-git")
+	}
+}
@@ -0,0 +1,73 @@
+package ghclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// HTTPConfig customizes the connections to GitHub.
+// The proxy is always taken from HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+type HTTPConfig struct {
+	// CABundleFile is a PEM file of CA certificates trusted in addition to the system ones.
+	CABundleFile string
+	// ClientCertFile and ClientKeyFile are the PEM encoded certificate and key presented to the server; both or neither must be set.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// InvalidCABundleError tells that the CA bundle cannot be read, or has no certificate if Err is nil.
+type InvalidCABundleError struct {
+	Err  error
+	Path string
+}
+
+func (e *InvalidCABundleError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("read CA bundle %s: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("no PEM encoded certificate found in CA bundle %s", e.Path)
+}
+
+func (e *InvalidCABundleError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path))
+}
+
+func (e *InvalidCABundleError) Unwrap() error { return e.Err }
+
+// NewHTTPTransport returns a RoundTripper configured as cfg tells, based on http.DefaultTransport.
+func NewHTTPTransport(cfg HTTPConfig) (http.RoundTripper, error) {
+	base, _ := http.DefaultTransport.(*http.Transport)
+	transport := base.Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.CABundleFile == "" && cfg.ClientCertFile == "" && cfg.ClientKeyFile == "" {
+		return transport, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CABundleFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pemBytes, err := os.ReadFile(cfg.CABundleFile)
+		if err != nil {
+			return nil, &InvalidCABundleError{Path: cfg.CABundleFile, Err: err}
+		}
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, &InvalidCABundleError{Path: cfg.CABundleFile}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
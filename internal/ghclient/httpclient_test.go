@@ -0,0 +1,107 @@
+package ghclient_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/ghclient"
+)
+
+func TestNewHTTPTransport(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	caFile := writePEM(t, dir, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	garbageFile := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbageFile, []byte("blah"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the server certificate doubles as the client certificate since the server does not verify it
+	serverCert := srv.TLS.Certificates[0]
+	certFile := writePEM(t, dir, "cert.pem", &pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Certificate[0]})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(serverCert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := writePEM(t, dir, "key.pem", &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	testCases := []struct {
+		name       string
+		cfg        ghclient.HTTPConfig
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "untrusted server", cfg: ghclient.HTTPConfig{}, wantErr: true},
+		{name: "CA bundle", cfg: ghclient.HTTPConfig{CABundleFile: caFile}, wantStatus: http.StatusUnauthorized},
+		{name: "client certificate", cfg: ghclient.HTTPConfig{CABundleFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}, wantStatus: http.StatusNoContent},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport, err := ghclient.NewHTTPTransport(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if tc.wantErr {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d; want %d", resp.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		_, err := ghclient.NewHTTPTransport(ghclient.HTTPConfig{CABundleFile: garbageFile})
+		invalidErr := new(ghclient.InvalidCABundleError)
+		if !errors.As(err, &invalidErr) {
+			t.Errorf("error = %v; want InvalidCABundleError", err)
+		}
+	})
+
+	t.Run("missing CA bundle", func(t *testing.T) {
+		_, err := ghclient.NewHTTPTransport(ghclient.HTTPConfig{CABundleFile: filepath.Join(t.TempDir(), "missing.pem")})
+		invalidErr := new(ghclient.InvalidCABundleError)
+		if !errors.As(err, &invalidErr) || !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("error = %v; want InvalidCABundleError of the missing file", err)
+		}
+	})
+}
+
+func writePEM(t *testing.T, dir, name string, block *pem.Block) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
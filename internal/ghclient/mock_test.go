@@ -0,0 +1,157 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/aereal/register-github-secret/internal/ghclient (interfaces: GHAppsService)
+
+// Package ghclient_test is a generated GoMock package.
+package ghclient_test
+
+import (
+	context "context"
+	reflect "reflect"
+
+	github "github.com/google/go-github/v69/github"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockGHAppsService is a mock of GHAppsService interface.
+type MockGHAppsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockGHAppsServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockGHAppsServiceMockRecorder is the mock recorder for MockGHAppsService.
+type MockGHAppsServiceMockRecorder struct {
+	mock *MockGHAppsService
+}
+
+// NewMockGHAppsService creates a new mock instance.
+func NewMockGHAppsService(ctrl *gomock.Controller) *MockGHAppsService {
+	mock := &MockGHAppsService{ctrl: ctrl}
+	mock.recorder = &MockGHAppsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGHAppsService) EXPECT() *MockGHAppsServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateInstallationToken mocks base method.
+func (m *MockGHAppsService) CreateInstallationToken(ctx context.Context, id int64, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInstallationToken", ctx, id, opts)
+	ret0, _ := ret[0].(*github.InstallationToken)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateInstallationToken indicates an expected call of CreateInstallationToken.
+func (mr *MockGHAppsServiceMockRecorder) CreateInstallationToken(ctx, id, opts any) *MockGHAppsServiceCreateInstallationTokenCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInstallationToken", reflect.TypeOf((*MockGHAppsService)(nil).CreateInstallationToken), ctx, id, opts)
+	return &MockGHAppsServiceCreateInstallationTokenCall{Call: call}
+}
+
+// MockGHAppsServiceCreateInstallationTokenCall wrap *gomock.Call
+type MockGHAppsServiceCreateInstallationTokenCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHAppsServiceCreateInstallationTokenCall) Return(arg0 *github.InstallationToken, arg1 *github.Response, arg2 error) *MockGHAppsServiceCreateInstallationTokenCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHAppsServiceCreateInstallationTokenCall) Do(f func(context.Context, int64, *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error)) *MockGHAppsServiceCreateInstallationTokenCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHAppsServiceCreateInstallationTokenCall) DoAndReturn(f func(context.Context, int64, *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error)) *MockGHAppsServiceCreateInstallationTokenCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// FindOrganizationInstallation mocks base method.
+func (m *MockGHAppsService) FindOrganizationInstallation(ctx context.Context, org string) (*github.Installation, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrganizationInstallation", ctx, org)
+	ret0, _ := ret[0].(*github.Installation)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindOrganizationInstallation indicates an expected call of FindOrganizationInstallation.
+func (mr *MockGHAppsServiceMockRecorder) FindOrganizationInstallation(ctx, org any) *MockGHAppsServiceFindOrganizationInstallationCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrganizationInstallation", reflect.TypeOf((*MockGHAppsService)(nil).FindOrganizationInstallation), ctx, org)
+	return &MockGHAppsServiceFindOrganizationInstallationCall{Call: call}
+}
+
+// MockGHAppsServiceFindOrganizationInstallationCall wrap *gomock.Call
+type MockGHAppsServiceFindOrganizationInstallationCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHAppsServiceFindOrganizationInstallationCall) Return(arg0 *github.Installation, arg1 *github.Response, arg2 error) *MockGHAppsServiceFindOrganizationInstallationCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHAppsServiceFindOrganizationInstallationCall) Do(f func(context.Context, string) (*github.Installation, *github.Response, error)) *MockGHAppsServiceFindOrganizationInstallationCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHAppsServiceFindOrganizationInstallationCall) DoAndReturn(f func(context.Context, string) (*github.Installation, *github.Response, error)) *MockGHAppsServiceFindOrganizationInstallationCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// FindUserInstallation mocks base method.
+func (m *MockGHAppsService) FindUserInstallation(ctx context.Context, user string) (*github.Installation, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUserInstallation", ctx, user)
+	ret0, _ := ret[0].(*github.Installation)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindUserInstallation indicates an expected call of FindUserInstallation.
+func (mr *MockGHAppsServiceMockRecorder) FindUserInstallation(ctx, user any) *MockGHAppsServiceFindUserInstallationCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserInstallation", reflect.TypeOf((*MockGHAppsService)(nil).FindUserInstallation), ctx, user)
+	return &MockGHAppsServiceFindUserInstallationCall{Call: call}
+}
+
+// MockGHAppsServiceFindUserInstallationCall wrap *gomock.Call
+type MockGHAppsServiceFindUserInstallationCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHAppsServiceFindUserInstallationCall) Return(arg0 *github.Installation, arg1 *github.Response, arg2 error) *MockGHAppsServiceFindUserInstallationCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHAppsServiceFindUserInstallationCall) Do(f func(context.Context, string) (*github.Installation, *github.Response, error)) *MockGHAppsServiceFindUserInstallationCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHAppsServiceFindUserInstallationCall) DoAndReturn(f func(context.Context, string) (*github.Installation, *github.Response, error)) *MockGHAppsServiceFindUserInstallationCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
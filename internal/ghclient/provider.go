@@ -0,0 +1,111 @@
+package ghclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"sync"
+
+	"github.com/google/go-github/v69/github"
+	"golang.org/x/sync/singleflight"
+)
+
+// Config selects how the clients authenticate to GitHub.
+// When AppID is set the clients act as installations of the GitHub App, otherwise Token is used as is.
+type Config struct {
+	AppPrivateKey *rsa.PrivateKey
+	// Transport carries the requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	Endpoint  Endpoint
+	Token     string
+	// UserAgent overrides the User-Agent header sent by go-github when non-empty.
+	UserAgent string
+	AppID     int64
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg, installationClients: map[string]*github.Client{}}
+}
+
+// Provider builds authenticated clients and hands out the one suitable for each repository owner.
+type Provider struct {
+	tokenClient         *github.Client
+	appClient           *github.Client
+	installationClients map[string]*github.Client
+	// lookups lets the targets of an owner share the lookup of its installation, while the other owners go on without waiting for it.
+	lookups singleflight.Group
+	cfg     Config
+	mux     sync.Mutex
+}
+
+func (p *Provider) Client(ctx context.Context, owner string) (*github.Client, error) {
+	p.mux.Lock()
+	if p.cfg.AppID == 0 {
+		defer p.mux.Unlock()
+		if p.tokenClient == nil {
+			c, err := p.newClient(StaticToken(p.cfg.Token))
+			if err != nil {
+				return nil, err
+			}
+			p.tokenClient = c
+		}
+		return p.tokenClient, nil
+	}
+	if c, ok := p.installationClients[owner]; ok {
+		p.mux.Unlock()
+		return c, nil
+	}
+	if p.appClient == nil {
+		c, err := p.newClient(NewAppJWTSource(p.cfg.AppID, p.cfg.AppPrivateKey))
+		if err != nil {
+			p.mux.Unlock()
+			return nil, err
+		}
+		p.appClient = c
+	}
+	appClient := p.appClient
+	p.mux.Unlock()
+	ch := p.lookups.DoChan(owner, func() (any, error) {
+		return p.installationClient(ctx, appClient, owner)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*github.Client), nil //nolint:forcetypeassert // installationClient returns nothing else
+	}
+}
+
+// installationClient looks up the installation of the GitHub App for the owner over the network, so it must be called without holding the lock.
+func (p *Provider) installationClient(ctx context.Context, appClient *github.Client, owner string) (*github.Client, error) {
+	installationID, err := FindInstallationID(ctx, appClient.Apps, owner)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.newClient(NewInstallationTokenSource(appClient.Apps, installationID))
+	if err != nil {
+		return nil, err
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.installationClients[owner] = c
+	return c, nil
+}
+
+func (p *Provider) newClient(source TokenSource) (*github.Client, error) {
+	httpClient := &http.Client{Transport: p.cfg.Transport}
+	if token, ok := source.(StaticToken); !ok || token != "" {
+		httpClient.Transport = &Transport{Base: p.cfg.Transport, Source: source}
+	}
+	c := github.NewClient(httpClient)
+	if p.cfg.UserAgent != "" {
+		c.UserAgent = p.cfg.UserAgent
+	}
+	if !p.cfg.Endpoint.IsEnterprise() {
+		return c, nil
+	}
+	return c.WithEnterpriseURLs(p.cfg.Endpoint.APIURL, p.cfg.Endpoint.UploadURL)
+}
@@ -0,0 +1,122 @@
+package ghclient_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestProvider_Client_enterprise(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/orgs/aereal/installation", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ey") {
+			http.Error(w, "JWT expected", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 42})
+	})
+	mux.HandleFunc("POST /api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"token": "installation-token", "expires_at": time.Now().Add(time.Hour)})
+	})
+	mux.HandleFunc("GET /api/v3/repos/aereal/myrepo", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer installation-token" && got != "Bearer static-token" {
+			http.Error(w, "unexpected token: "+got, http.StatusUnauthorized)
+			return
+		}
+		if got := r.Header.Get("User-Agent"); got != "my-agent" {
+			http.Error(w, "unexpected User-Agent: "+got, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": "myrepo"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	endpoint, err := ghclient.ParseEndpoint(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		cfg  ghclient.Config
+	}{
+		{name: "token", cfg: ghclient.Config{Endpoint: endpoint, Token: "static-token", UserAgent: "my-agent"}},
+		{name: "GitHub App", cfg: ghclient.Config{Endpoint: endpoint, AppID: 12345, AppPrivateKey: generateKey(t), UserAgent: "my-agent"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ghclient.NewProvider(tc.cfg).Client(t.Context(), "aereal")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := client.UploadURL.String(), srv.URL+"/api/uploads/"; got != want {
+				t.Errorf("upload URL = %q; want %q", got, want)
+			}
+			repo, _, err := client.Repositories.Get(t.Context(), "aereal", "myrepo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if repo.GetName() != "myrepo" {
+				t.Errorf("repository name = %q", repo.GetName())
+			}
+		})
+	}
+}
+
+func TestProvider_Client_concurrent(t *testing.T) {
+	release := make(chan struct{})
+	var lookups atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/orgs/{org}/installation", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("org") == "slow" {
+			lookups.Add(1)
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 42})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	endpoint, err := ghclient.ParseEndpoint(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := ghclient.NewProvider(ghclient.Config{Endpoint: endpoint, AppID: 12345, AppPrivateKey: generateKey(t)})
+	var wg sync.WaitGroup
+	slowClients := make([]*github.Client, 2)
+	for i := range slowClients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := provider.Client(t.Context(), "slow")
+			if err != nil {
+				t.Error(err)
+			}
+			slowClients[i] = c
+		}()
+	}
+	// the other owners must not wait for the lookup of the slow one
+	if _, err := provider.Client(t.Context(), "aereal"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	wg.Wait()
+	if slowClients[0] != slowClients[1] {
+		t.Error("the targets of an owner must share the client")
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("the installation of the owner must be looked up once but %d times", got)
+	}
+}
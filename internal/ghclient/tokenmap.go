@@ -0,0 +1,105 @@
+package ghclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// TokenMap tells which credential source holds the token for each repository owner.
+type TokenMap struct {
+	sources map[string]CredentialSource
+	path    string
+}
+
+type InvalidCredentialSpecError struct {
+	Key  string
+	Spec string
+}
+
+func (e *InvalidCredentialSpecError) Error() string {
+	return fmt.Sprintf("invalid credential source %q for %s; want env:NAME, file:PATH, gh or git", e.Spec, e.Key)
+}
+
+func (e *InvalidCredentialSpecError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("key", e.Key), slog.String("spec", e.Spec))
+}
+
+// UnmappedOwnerError tells that the token map has no entry for the owner.
+type UnmappedOwnerError struct {
+	Path  string
+	Host  string
+	Owner string
+}
+
+func (e *UnmappedOwnerError) Error() string {
+	return fmt.Sprintf("no credential is mapped to %s/%s in %s", e.Host, e.Owner, e.Path)
+}
+
+func (e *UnmappedOwnerError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("path", e.Path), slog.String("host", e.Host), slog.String("owner", e.Owner))
+}
+
+func (e *UnmappedOwnerError) Is(err error) bool { return err == ErrNoCredential }
+
+// LoadTokenMap reads the JSON object at path that maps an owner to a credential source such as:
+//
+//	{"aereal": "env:AEREAL_TOKEN", "ghe.example.com/platform": "file:/run/secrets/platform-token"}
+//
+// Keys are OWNER for owners on defaultHost and HOST/OWNER for the others.
+// Values are env:NAME, file:PATH, gh (the token stored by the gh CLI for the host) or git (the git credential helper).
+func LoadTokenMap(path, defaultHost string) (*TokenMap, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read token map: %w", err)
+	}
+	var specs map[string]string
+	if err := json.Unmarshal(b, &specs); err != nil {
+		return nil, fmt.Errorf("parse token map %s: %w", path, err)
+	}
+	m := &TokenMap{path: path, sources: make(map[string]CredentialSource, len(specs))}
+	for key, spec := range specs {
+		host, owner, ok := strings.Cut(key, "/")
+		if !ok {
+			host, owner = defaultHost, key
+		}
+		src, ok := parseCredentialSpec(spec, strings.ToLower(host))
+		if !ok {
+			return nil, &InvalidCredentialSpecError{Key: key, Spec: spec}
+		}
+		m.sources[tokenMapKey(host, owner)] = src
+	}
+	return m, nil
+}
+
+// Source returns the credential source mapped to the owner on the host.
+func (m *TokenMap) Source(host, owner string) (CredentialSource, error) {
+	src, ok := m.sources[tokenMapKey(host, owner)]
+	if !ok {
+		return nil, &UnmappedOwnerError{Path: m.path, Host: host, Owner: owner}
+	}
+	return src, nil
+}
+
+// tokenMapKey folds the case because GitHub treats host and owner names case-insensitively.
+func tokenMapKey(host, owner string) string {
+	return strings.ToLower(host) + "/" + strings.ToLower(owner)
+}
+
+func parseCredentialSpec(spec, host string) (CredentialSource, bool) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case kind == "env" && arg != "":
+		return EnvCredential(arg), true
+	case kind == "file" && arg != "":
+		return FileCredential(arg), true
+	case spec == "gh":
+		return &GHCLIHostsCredential{Path: DefaultGHHostsPath(), Host: host}, true
+	case spec == "git":
+		return &GitCredentialHelper{Host: host}, true
+	default:
+		return nil, false
+	}
+}
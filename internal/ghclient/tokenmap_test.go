@@ -0,0 +1,60 @@
+package ghclient_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+)
+
+func TestTokenMap_Source(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "tokens.json")
+	tokenFile := filepath.Join(dir, "platform-token")
+	content := `{"aereal": "env:TEST_AEREAL_TOKEN", "GHE.example.com/Platform": "file:` + tokenFile + `"}`
+	if err := os.WriteFile(mapFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokenMap, err := ghclient.LoadTokenMap(mapFile, "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		wantErr error
+		name    string
+		host    string
+		owner   string
+		want    string
+	}{
+		{name: "owner on the default host", host: "github.com", owner: "aereal", want: "env:TEST_AEREAL_TOKEN"},
+		{name: "case insensitive", host: "github.com", owner: "AEREAL", want: "env:TEST_AEREAL_TOKEN"},
+		{name: "owner on another host", host: "ghe.example.com", owner: "platform", want: "file:" + tokenFile},
+		{name: "same owner on another host", host: "ghe.example.com", owner: "aereal", wantErr: assertions.LiteralError("no credential is mapped to ghe.example.com/aereal in " + mapFile)},
+		{name: "unmapped", host: "github.com", owner: "other", wantErr: ghclient.ErrNoCredential},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, gotErr := tokenMap.Source(tc.host, tc.owner)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if gotErr == nil && src.Name() != tc.want {
+				t.Errorf("source = %q; want %q", src.Name(), tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokenMap_invalid(t *testing.T) {
+	mapFile := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(mapFile, []byte(`{"aereal": "vault:secret/token"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, gotErr := ghclient.LoadTokenMap(mapFile, "github.com")
+	wantErr := assertions.LiteralError(`invalid credential source "vault:secret/token" for aereal; want env:NAME, file:PATH, gh or git`)
+	if diff := assertions.DiffErrorsConservatively(wantErr, gotErr); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+}
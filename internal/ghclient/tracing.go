@@ -0,0 +1,146 @@
+package ghclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/log"
+)
+
+// maxTracedBody is the number of bytes of a body written to the log; the rest is cut off.
+const maxTracedBody = 4096
+
+// redactedBodyFields are the JSON fields of the bodies masked in the log wherever they appear.
+var redactedBodyFields = []string{"encrypted_value", "token"}
+
+// TracingTransport logs each request and its response at the debug level, doing nothing else unless the logger is enabled for it.
+// The Authorization header and the encrypted values are masked; the response body is written only for the failed requests.
+// It must be placed under Transport so that the Authorization header it masks is the one actually sent.
+type TracingTransport struct {
+	Base http.RoundTripper
+	// Logger is nil to use slog.Default.
+	Logger *slog.Logger
+}
+
+var _ http.RoundTripper = (*TracingTransport)(nil)
+
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	logger := t.logger()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return t.base().RoundTrip(req)
+	}
+	reqAttrs := []any{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.Group("headers", tracedHeaders(req.Header)...),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(ctx)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		reqAttrs = append(reqAttrs, slog.String("body", redactBody(body)))
+	}
+	startedAt := time.Now()
+	resp, err := t.base().RoundTrip(req)
+	attrs := []any{
+		slog.Group("http.request", reqAttrs...),
+		slog.Duration("http.latency", time.Since(startedAt)),
+	}
+	if err != nil {
+		logger.DebugContext(ctx, "HTTP request failed", append(attrs, log.AttrError(err))...)
+		return nil, err
+	}
+	respAttrs := []any{
+		slog.Int("status", resp.StatusCode),
+		slog.String("request_id", resp.Header.Get("X-GitHub-Request-Id")),
+		slog.Group("rate_limit",
+			slog.String("limit", resp.Header.Get("X-RateLimit-Limit")),
+			slog.String("remaining", resp.Header.Get("X-RateLimit-Remaining")),
+			slog.String("used", resp.Header.Get("X-RateLimit-Used")),
+			slog.String("reset", resp.Header.Get("X-RateLimit-Reset")),
+			slog.String("resource", resp.Header.Get("X-RateLimit-Resource")),
+		),
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, readErr := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		respAttrs = append(respAttrs, slog.String("body", redactBody(body)))
+	}
+	logger.DebugContext(ctx, "HTTP request", append(attrs, slog.Group("http.response", respAttrs...))...)
+	return resp, nil
+}
+
+func (t *TracingTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *TracingTransport) logger() *slog.Logger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return slog.Default()
+}
+
+func tracedHeaders(header http.Header) []any {
+	names := slices.Sorted(maps.Keys(header))
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if name == "Authorization" {
+			value = log.RedactedMarker
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return attrs
+}
+
+// redactBody masks redactedBodyFields of a JSON body; the other bodies are written as they are.
+func redactBody(body []byte) string {
+	var v any
+	if json.Unmarshal(body, &v) == nil {
+		if b, err := json.Marshal(redactJSON(v)); err == nil {
+			body = b
+		}
+	}
+	if len(body) > maxTracedBody {
+		return string(body[:maxTracedBody]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+func redactJSON(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, fv := range x {
+			if slices.Contains(redactedBodyFields, k) {
+				x[k] = log.RedactedMarker
+				continue
+			}
+			x[k] = redactJSON(fv)
+		}
+	case []any:
+		for i, ev := range x {
+			x[i] = redactJSON(ev)
+		}
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package ghclient_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestTracingTransport(t *testing.T) {
+	var received []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /repos/aereal/repo1/actions/secrets/MY_SECRET", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+		w.Header().Set("X-GitHub-Request-Id", "ABCD:1234")
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Used", "1")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		w.Header().Set("X-RateLimit-Resource", "core")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = io.WriteString(w, `{"message":"Bad request - invalid key_id"}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	const body = `{"encrypted_value":"c2VhbGVk","key_id":"0x1"}`
+
+	testCases := []struct {
+		name  string
+		want  []map[string]any
+		level slog.Level
+	}{
+		{
+			name:  "debug",
+			level: slog.LevelDebug,
+			want: []map[string]any{
+				{
+					slog.LevelKey:   "DEBUG",
+					slog.MessageKey: "HTTP request",
+					"http.request": map[string]any{
+						"method": http.MethodPut,
+						"url":    srv.URL + "/repos/aereal/repo1/actions/secrets/MY_SECRET",
+						"headers": map[string]any{
+							"Authorization": log.RedactedMarker,
+							"Content-Type":  "application/json",
+						},
+						"body": `{"encrypted_value":"` + log.RedactedMarker + `","key_id":"0x1"}`,
+					},
+					"http.response": map[string]any{
+						"status":     float64(http.StatusUnprocessableEntity),
+						"request_id": "ABCD:1234",
+						"rate_limit": map[string]any{
+							"limit":     "5000",
+							"remaining": "4999",
+							"used":      "1",
+							"reset":     "1700000000",
+							"resource":  "core",
+						},
+						"body": `{"message":"Bad request - invalid key_id"}`,
+					},
+				},
+			},
+		},
+		{
+			name:  "info",
+			level: slog.LevelInfo,
+			want:  []map[string]any{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			received = nil
+			out := new(bytes.Buffer)
+			transport := &ghclient.Transport{
+				Base:   &ghclient.TracingTransport{Logger: slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: tc.level}))},
+				Source: ghclient.StaticToken("s3cr3t"),
+			}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, srv.URL+"/repos/aereal/repo1/actions/secrets/MY_SECRET", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotBody, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(gotBody), `{"message":"Bad request - invalid key_id"}`; got != want {
+				t.Errorf("response body: want=%q got=%q", want, got)
+			}
+			if diff := cmp.Diff([]string{body}, received); diff != "" {
+				t.Errorf("sent body (-want, +got):\n%s", diff)
+			}
+			if strings.Contains(out.String(), "s3cr3t") || strings.Contains(out.String(), "c2VhbGVk") {
+				t.Errorf("the secrets are logged: %s", out.String())
+			}
+			got := []map[string]any{}
+			dec := json.NewDecoder(out)
+			for dec.More() {
+				entry := map[string]any{}
+				if err := dec.Decode(&entry); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, entry)
+			}
+			ignoreVolatile := cmpopts.IgnoreMapEntries(func(key string, _ any) bool {
+				return key == slog.TimeKey || key == "http.latency"
+			})
+			if diff := cmp.Diff(tc.want, got, ignoreVolatile); diff != "" {
+				t.Errorf("logs (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package ghclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// TokenSource yields the bearer token sent to GitHub; implementations may refresh it on each call.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that never changes, e.g. a personal access token.
+type StaticToken string
+
+var _ TokenSource = StaticToken("")
+
+func (t StaticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// Transport authenticates each request with a token taken from Source.
+type Transport struct {
+	Base   http.RoundTripper
+	Source TokenSource
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base().RoundTrip(req)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,64 @@
+package ghclient
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+const publicHost = "github.com"
+
+type InvalidGitHubURLError struct {
+	Input  string
+	Reason string
+}
+
+func (e *InvalidGitHubURLError) Error() string {
+	return fmt.Sprintf("invalid GitHub URL %q: %s", e.Input, e.Reason)
+}
+
+func (e *InvalidGitHubURLError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("input", e.Input), slog.String("reason", e.Reason))
+}
+
+// Endpoint is the GitHub instance the clients talk to.
+type Endpoint struct {
+	// APIURL and UploadURL are empty for github.com.
+	APIURL    string
+	UploadURL string
+	// Host is the web host used to look up stored credentials, e.g. github.com or ghe.example.com.
+	// It keeps the port if the URL has one, such as ghe.example.com:8443.
+	Host string
+}
+
+func (e Endpoint) IsEnterprise() bool { return e.APIURL != "" }
+
+// ParseEndpoint accepts either the root URL of a GitHub Enterprise Server (https://ghe.example.com) or its API URL
+// (https://ghe.example.com/api/v3) such as GITHUB_API_URL set by GitHub Actions. An empty string or the github.com API means github.com.
+func ParseEndpoint(rawURL string) (Endpoint, error) {
+	if rawURL == "" {
+		return Endpoint{Host: publicHost}, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Endpoint{}, &InvalidGitHubURLError{Input: rawURL, Reason: err.Error()}
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return Endpoint{}, &InvalidGitHubURLError{Input: rawURL, Reason: "scheme must be http or https"}
+	}
+	if u.Host == "" {
+		return Endpoint{}, &InvalidGitHubURLError{Input: rawURL, Reason: "host is missing"}
+	}
+	if u.Host == "api."+publicHost || u.Host == publicHost {
+		return Endpoint{Host: publicHost}, nil
+	}
+	root := *u
+	root.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3")
+	root.RawQuery, root.Fragment = "", ""
+	return Endpoint{
+		APIURL:    root.JoinPath("api", "v3").String() + "/",
+		UploadURL: root.JoinPath("api", "uploads").String() + "/",
+		Host:      u.Host,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package ghclient_test
+
+import (
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/ghclient"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	ghe := ghclient.Endpoint{
+		APIURL:    "https://ghe.example.com/api/v3/",
+		UploadURL: "https://ghe.example.com/api/uploads/",
+		Host:      "ghe.example.com",
+	}
+	testCases := []struct {
+		wantErr error
+		name    string
+		input   string
+		want    ghclient.Endpoint
+	}{
+		{name: "empty", input: "", want: ghclient.Endpoint{Host: "github.com"}},
+		{name: "github.com API", input: "https://api.github.com", want: ghclient.Endpoint{Host: "github.com"}},
+		{name: "github.com", input: "https://github.com/", want: ghclient.Endpoint{Host: "github.com"}},
+		{name: "GHES root", input: "https://ghe.example.com", want: ghe},
+		{name: "GHES root with trailing slash", input: "https://ghe.example.com/", want: ghe},
+		{name: "GHES API", input: "https://ghe.example.com/api/v3", want: ghe},
+		{name: "GHES API with trailing slash", input: "https://ghe.example.com/api/v3/", want: ghe},
+		{
+			name:  "GHES with port and path prefix",
+			input: "https://example.com:8443/github/api/v3",
+			want: ghclient.Endpoint{
+				APIURL:    "https://example.com:8443/github/api/v3/",
+				UploadURL: "https://example.com:8443/github/api/uploads/",
+				Host:      "example.com:8443",
+			},
+		},
+		{
+			name:  "GHES root with port",
+			input: "https://ghe.example.com:8443",
+			want: ghclient.Endpoint{
+				APIURL:    "https://ghe.example.com:8443/api/v3/",
+				UploadURL: "https://ghe.example.com:8443/api/uploads/",
+				Host:      "ghe.example.com:8443",
+			},
+		},
+		{name: "no scheme", input: "ghe.example.com", wantErr: assertions.LiteralError(`invalid GitHub URL "ghe.example.com": scheme must be http or https`)},
+		{name: "no host", input: "https://", wantErr: assertions.LiteralError(`invalid GitHub URL "https://": host is missing`)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotErr := ghclient.ParseEndpoint(tc.input)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("endpoint (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,613 @@
+// Package ghfake provides an in-process fake of the GitHub REST API for Actions secrets and variables.
+//
+// The fake holds its own NaCl keypair, so the secrets uploaded to it can be decrypted and inspected by the tests.
+// It serves the API under /api/v3 like GitHub Enterprise Server, so a client is pointed to it by its URL.
+package ghfake
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+type Option func(*Server)
+
+// WithToken makes the server refuse the requests without the bearer token.
+func WithToken(token string) Option {
+	return func(s *Server) { s.token = token }
+}
+
+// WithPerPage sets the default page size of the list endpoints; GitHub uses 30.
+func WithPerPage(n int) Option {
+	return func(s *Server) { s.perPage = n }
+}
+
+// WithLogin sets the login of the user the token belongs to; it defaults to octocat.
+func WithLogin(login string) Option {
+	return func(s *Server) { s.login = login }
+}
+
+// WithStalledSecretWrites makes the server never answer the requests writing secrets until the client gives up.
+func WithStalledSecretWrites() Option {
+	return func(s *Server) { s.stallSecretWrites = true }
+}
+
+// NewServer starts the fake server, which is closed when the test finishes.
+func NewServer(tb testing.TB, opts ...Option) *Server {
+	tb.Helper()
+	s := &Server{
+		perPage:   30,
+		login:     "octocat",
+		repos:     map[string]*repository{},
+		repoIDs:   map[int64]*repository{},
+		orgs:      map[string]bool{},
+		secrets:   map[string]map[string]*storedSecret{},
+		variables: map[string]map[string]*storedVariable{},
+	}
+	for _, f := range opts {
+		f(s)
+	}
+	if err := s.RotateKey(); err != nil {
+		tb.Fatal(err)
+	}
+	s.srv = httptest.NewServer(http.StripPrefix("/api/v3", s.routes()))
+	tb.Cleanup(s.srv.Close)
+	return s
+}
+
+// Server is the fake GitHub. The repositories and organizations are unknown to it until they are added.
+type Server struct {
+	srv        *httptest.Server
+	publicKey  *[32]byte
+	privateKey *[32]byte
+	repos      map[string]*repository
+	repoIDs    map[int64]*repository
+	orgs       map[string]bool
+	// secrets and variables are keyed by the scope such as repos/OWNER/REPO and then by the name.
+	secrets   map[string]map[string]*storedSecret
+	variables map[string]map[string]*storedVariable
+	token     string
+	login     string
+	keyID     string
+	nextID    int64
+	perPage   int
+	// keyGeneration counts the keypairs to give each of them a distinct ID.
+	keyGeneration     int
+	mux               sync.Mutex
+	stallSecretWrites bool
+}
+
+type repository struct {
+	owner    string
+	name     string
+	id       int64
+	archived bool
+}
+
+type storedSecret struct {
+	createdAt  time.Time
+	updatedAt  time.Time
+	value      string
+	visibility string
+}
+
+type storedVariable struct {
+	createdAt  time.Time
+	updatedAt  time.Time
+	value      string
+	visibility string
+}
+
+// URL is the root URL of the server, to be given as the URL of a GitHub Enterprise Server.
+func (s *Server) URL() string { return s.srv.URL }
+
+// AddRepository makes the repository known and returns its ID, which the environment endpoints take.
+func (s *Server) AddRepository(owner, name string) int64 {
+	return s.addRepository(owner, name, false)
+}
+
+// AddArchivedRepository adds a repository that is listed as archived.
+func (s *Server) AddArchivedRepository(owner, name string) int64 {
+	return s.addRepository(owner, name, true)
+}
+
+func (s *Server) addRepository(owner, name string, archived bool) int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if r, ok := s.repos[repoKey(owner, name)]; ok {
+		return r.id
+	}
+	s.nextID++
+	r := &repository{id: s.nextID, owner: owner, name: name, archived: archived}
+	s.repos[repoKey(owner, name)] = r
+	s.repoIDs[r.id] = r
+	return r.id
+}
+
+// AddOrganization makes the organization known; its repositories are the ones added with it as the owner.
+func (s *Server) AddOrganization(org string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.orgs[strings.ToLower(org)] = true
+}
+
+// KeyID is the ID of the current public key.
+func (s *Server) KeyID() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.keyID
+}
+
+// RotateKey replaces the keypair; the secrets sealed with the previous key are refused from then on.
+func (s *Server) RotateKey() error {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.publicKey, s.privateKey = pub, priv
+	s.keyGeneration++
+	s.keyID = "key-" + strconv.Itoa(s.keyGeneration)
+	return nil
+}
+
+// RepoSecret returns the decrypted value of the repository secret.
+func (s *Server) RepoSecret(owner, repo, name string) (string, bool) {
+	return s.secret(repoScope(owner, repo), name)
+}
+
+// EnvSecret returns the decrypted value of the secret of the environment of the repository.
+func (s *Server) EnvSecret(owner, repo, env, name string) (string, bool) {
+	return s.secret(envScope(owner, repo, env), name)
+}
+
+// OrgSecret returns the decrypted value of the organization secret.
+func (s *Server) OrgSecret(org, name string) (string, bool) {
+	return s.secret(orgScope(org), name)
+}
+
+func (s *Server) RepoVariable(owner, repo, name string) (string, bool) {
+	return s.variable(repoScope(owner, repo), name)
+}
+
+func (s *Server) EnvVariable(owner, repo, env, name string) (string, bool) {
+	return s.variable(envScope(owner, repo, env), name)
+}
+
+func (s *Server) OrgVariable(org, name string) (string, bool) {
+	return s.variable(orgScope(org), name)
+}
+
+func (s *Server) secret(scope, name string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	sec, ok := s.secrets[scope][strings.ToUpper(name)]
+	if !ok {
+		return "", false
+	}
+	return sec.value, true
+}
+
+func (s *Server) variable(scope, name string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.variables[scope][strings.ToUpper(name)]
+	if !ok {
+		return "", false
+	}
+	return v.value, true
+}
+
+func repoKey(owner, repo string) string { return strings.ToLower(owner + "/" + repo) }
+
+func repoScope(owner, repo string) string { return "repos/" + repoKey(owner, repo) }
+
+func envScope(owner, repo, env string) string { return repoScope(owner, repo) + "/environments/" + env }
+
+func orgScope(org string) string { return "orgs/" + strings.ToLower(org) }
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user", s.handleGetUser)
+	mux.HandleFunc("GET /repos/{owner}/{repo}", s.handleGetRepository)
+	mux.HandleFunc("GET /orgs/{org}/repos", s.handleListOrgRepositories)
+
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/secrets/public-key", s.withRepo(s.handleGetPublicKey))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/secrets", s.withRepo(s.handleListSecrets))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/secrets/{name}", s.withRepo(s.handleGetSecret))
+	mux.HandleFunc("PUT /repos/{owner}/{repo}/actions/secrets/{name}", s.withRepo(s.handlePutSecret))
+	mux.HandleFunc("DELETE /repos/{owner}/{repo}/actions/secrets/{name}", s.withRepo(s.handleDeleteSecret))
+
+	mux.HandleFunc("GET /repositories/{id}/environments/{env}/secrets/public-key", s.withRepoID(s.handleGetPublicKey))
+	mux.HandleFunc("GET /repositories/{id}/environments/{env}/secrets", s.withRepoID(s.handleListSecrets))
+	mux.HandleFunc("GET /repositories/{id}/environments/{env}/secrets/{name}", s.withRepoID(s.handleGetSecret))
+	mux.HandleFunc("PUT /repositories/{id}/environments/{env}/secrets/{name}", s.withRepoID(s.handlePutSecret))
+	mux.HandleFunc("DELETE /repositories/{id}/environments/{env}/secrets/{name}", s.withRepoID(s.handleDeleteSecret))
+
+	mux.HandleFunc("GET /orgs/{org}/actions/secrets/public-key", s.withOrg(s.handleGetPublicKey))
+	mux.HandleFunc("GET /orgs/{org}/actions/secrets", s.withOrg(s.handleListSecrets))
+	mux.HandleFunc("GET /orgs/{org}/actions/secrets/{name}", s.withOrg(s.handleGetSecret))
+	mux.HandleFunc("PUT /orgs/{org}/actions/secrets/{name}", s.withOrg(s.handlePutSecret))
+	mux.HandleFunc("DELETE /orgs/{org}/actions/secrets/{name}", s.withOrg(s.handleDeleteSecret))
+
+	for prefix, with := range map[string]func(scopedHandler) http.HandlerFunc{
+		"/repos/{owner}/{repo}/actions/variables":            s.withRepo,
+		"/repos/{owner}/{repo}/environments/{env}/variables": s.withRepo,
+		"/orgs/{org}/actions/variables":                      s.withOrg,
+	} {
+		mux.HandleFunc("GET "+prefix, with(s.handleListVariables))
+		mux.HandleFunc("POST "+prefix, with(s.handleCreateVariable))
+		mux.HandleFunc("GET "+prefix+"/{name}", with(s.handleGetVariable))
+		mux.HandleFunc("PATCH "+prefix+"/{name}", with(s.handleUpdateVariable))
+		mux.HandleFunc("DELETE "+prefix+"/{name}", with(s.handleDeleteVariable))
+	}
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scopedHandler serves a request on the secrets or the variables of the scope.
+type scopedHandler func(w http.ResponseWriter, r *http.Request, scope string)
+
+func (s *Server) withRepo(h scopedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, repo := r.PathValue("owner"), r.PathValue("repo")
+		s.mux.Lock()
+		_, ok := s.repos[repoKey(owner, repo)]
+		s.mux.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		scope := repoScope(owner, repo)
+		if env := r.PathValue("env"); env != "" {
+			scope = envScope(owner, repo, env)
+		}
+		h(w, r, scope)
+	}
+}
+
+func (s *Server) withRepoID(h scopedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		s.mux.Lock()
+		repo, ok := s.repoIDs[id]
+		s.mux.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		h(w, r, envScope(repo.owner, repo.name, r.PathValue("env")))
+	}
+}
+
+func (s *Server) withOrg(h scopedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org := r.PathValue("org")
+		s.mux.Lock()
+		ok := s.orgs[strings.ToLower(org)]
+		s.mux.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		h(w, r, orgScope(org))
+	}
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"login": s.login, "type": "User"})
+}
+
+func (s *Server) handleGetRepository(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	repo, ok := s.repos[repoKey(r.PathValue("owner"), r.PathValue("repo"))]
+	s.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, repo.toJSON())
+}
+
+func (s *Server) handleListOrgRepositories(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	s.mux.Lock()
+	if !s.orgs[strings.ToLower(org)] {
+		s.mux.Unlock()
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var repos []*repository
+	for _, repo := range s.repos {
+		if strings.EqualFold(repo.owner, org) {
+			repos = append(repos, repo)
+		}
+	}
+	s.mux.Unlock()
+	slices.SortFunc(repos, func(a, b *repository) int { return cmp.Compare(strings.ToLower(a.name), strings.ToLower(b.name)) })
+	page := s.paginate(w, r, len(repos))
+	body := make([]map[string]any, 0, page.len())
+	for _, repo := range repos[page.start:page.end] {
+		body = append(body, repo.toJSON())
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (r *repository) toJSON() map[string]any {
+	return map[string]any{
+		"id":          r.id,
+		"name":        r.name,
+		"full_name":   r.owner + "/" + r.name,
+		"owner":       map[string]any{"login": r.owner},
+		"archived":    r.archived,
+		"permissions": map[string]bool{"admin": true, "push": true, "pull": true},
+	}
+}
+
+func (s *Server) handleGetPublicKey(w http.ResponseWriter, _ *http.Request, _ string) {
+	s.mux.Lock()
+	body := map[string]string{"key_id": s.keyID, "key": base64.StdEncoding.EncodeToString(s.publicKey[:])}
+	s.mux.Unlock()
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request, scope string) {
+	s.mux.Lock()
+	names := slices.Sorted(maps.Keys(s.secrets[scope]))
+	page := s.paginate(w, r, len(names))
+	secrets := make([]map[string]any, 0, page.len())
+	for _, name := range names[page.start:page.end] {
+		secrets = append(secrets, secretJSON(name, s.secrets[scope][name]))
+	}
+	s.mux.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"total_count": len(names), "secrets": secrets})
+}
+
+func (s *Server) handleGetSecret(w http.ResponseWriter, r *http.Request, scope string) {
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	sec, ok := s.secrets[scope][name]
+	s.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, secretJSON(name, sec))
+}
+
+func secretJSON(name string, sec *storedSecret) map[string]any {
+	body := map[string]any{"name": name, "created_at": sec.createdAt, "updated_at": sec.updatedAt}
+	if sec.visibility != "" {
+		body["visibility"] = sec.visibility
+	}
+	return body
+}
+
+// handlePutSecret decrypts the value with the current key and answers 422 like GitHub when it was sealed with another key.
+func (s *Server) handlePutSecret(w http.ResponseWriter, r *http.Request, scope string) {
+	if s.stallSecretWrites {
+		// the server notices the client going away only after the body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		return
+	}
+	var body struct {
+		EncryptedValue string `json:"encrypted_value"`
+		KeyID          string `json:"key_id"`
+		Visibility     string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if body.KeyID != s.keyID {
+		writeError(w, http.StatusUnprocessableEntity, "Bad request - key_id is not the current one")
+		return
+	}
+	sealed, err := base64.StdEncoding.DecodeString(body.EncryptedValue)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Bad request - encrypted_value is not base64")
+		return
+	}
+	plain, ok := box.OpenAnonymous(nil, sealed, s.publicKey, s.privateKey)
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Bad request - encrypted_value cannot be decrypted")
+		return
+	}
+	if s.secrets[scope] == nil {
+		s.secrets[scope] = map[string]*storedSecret{}
+	}
+	now := time.Now().UTC()
+	if sec, exists := s.secrets[scope][name]; exists {
+		sec.value, sec.updatedAt, sec.visibility = string(plain), now, body.Visibility
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.secrets[scope][name] = &storedSecret{value: string(plain), visibility: body.Visibility, createdAt: now, updatedAt: now}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request, scope string) {
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	_, ok := s.secrets[scope][name]
+	delete(s.secrets[scope], name)
+	s.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListVariables(w http.ResponseWriter, r *http.Request, scope string) {
+	s.mux.Lock()
+	names := slices.Sorted(maps.Keys(s.variables[scope]))
+	page := s.paginate(w, r, len(names))
+	variables := make([]map[string]any, 0, page.len())
+	for _, name := range names[page.start:page.end] {
+		variables = append(variables, variableJSON(name, s.variables[scope][name]))
+	}
+	s.mux.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"total_count": len(names), "variables": variables})
+}
+
+func (s *Server) handleGetVariable(w http.ResponseWriter, r *http.Request, scope string) {
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	v, ok := s.variables[scope][name]
+	s.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, variableJSON(name, v))
+}
+
+func variableJSON(name string, v *storedVariable) map[string]any {
+	body := map[string]any{"name": name, "value": v.value, "created_at": v.createdAt, "updated_at": v.updatedAt}
+	if v.visibility != "" {
+		body["visibility"] = v.visibility
+	}
+	return body
+}
+
+type variableBody struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Visibility string `json:"visibility"`
+}
+
+func (s *Server) handleCreateVariable(w http.ResponseWriter, r *http.Request, scope string) {
+	var body variableBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request - name is required")
+		return
+	}
+	name := strings.ToUpper(body.Name)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, exists := s.variables[scope][name]; exists {
+		writeError(w, http.StatusConflict, "Already exists")
+		return
+	}
+	if s.variables[scope] == nil {
+		s.variables[scope] = map[string]*storedVariable{}
+	}
+	now := time.Now().UTC()
+	s.variables[scope][name] = &storedVariable{value: body.Value, visibility: body.Visibility, createdAt: now, updatedAt: now}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleUpdateVariable(w http.ResponseWriter, r *http.Request, scope string) {
+	var body variableBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.variables[scope][name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	v.value, v.updatedAt = body.Value, time.Now().UTC()
+	if body.Visibility != "" {
+		v.visibility = body.Visibility
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteVariable(w http.ResponseWriter, r *http.Request, scope string) {
+	name := strings.ToUpper(r.PathValue("name"))
+	s.mux.Lock()
+	_, ok := s.variables[scope][name]
+	delete(s.variables[scope], name)
+	s.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pageRange is the slice of the items served on the requested page.
+type pageRange struct{ start, end int }
+
+func (p pageRange) len() int { return p.end - p.start }
+
+// paginate reads page and per_page of the request, and sets the Link header go-github follows to the next page.
+func (s *Server) paginate(w http.ResponseWriter, r *http.Request, total int) pageRange {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = s.perPage
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+	lastPage := max((total+perPage-1)/perPage, 1)
+	link := func(p int, rel string) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		return fmt.Sprintf(`<%s/api/v3%s?%s>; rel="%s"`, s.srv.URL, r.URL.Path, q.Encode(), rel)
+	}
+	var links []string
+	if page < lastPage {
+		links = append(links, link(page+1, "next"), link(lastPage, "last"))
+	}
+	if page > 1 {
+		links = append(links, link(1, "first"), link(page-1, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return pageRange{start: start, end: end}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message, "documentation_url": "https://docs.github.com/rest"})
+}
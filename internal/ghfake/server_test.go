@@ -0,0 +1,197 @@
+package ghfake_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/ghfake"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func newClient(t *testing.T, srv *ghfake.Server, token string) *github.Client {
+	t.Helper()
+	c, err := github.NewClient(nil).WithAuthToken(token).WithEnterpriseURLs(srv.URL(), srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func seal(t *testing.T, key *github.PublicKey, name, value string) *github.EncryptedSecret {
+	t.Helper()
+	sealed, err := usecases.SealSecret(key, name, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &github.EncryptedSecret{Name: sealed.Name, KeyID: sealed.KeyID, EncryptedValue: sealed.EncryptedValue}
+}
+
+func statusCode(resp *github.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+func TestServer_secrets(t *testing.T) {
+	srv := ghfake.NewServer(t, ghfake.WithToken("token"))
+	repoID := srv.AddRepository("aereal", "repo1")
+	srv.AddOrganization("myorg")
+	client := newClient(t, srv, "token")
+	ctx := t.Context()
+
+	key, _, err := client.Actions.GetRepoPublicKey(ctx, "aereal", "repo1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Actions.CreateOrUpdateRepoSecret(ctx, "aereal", "repo1", seal(t, key, "MY_SECRET", "v1"))
+	if got := statusCode(resp); err != nil || got != http.StatusCreated {
+		t.Fatalf("create: status=%d err=%v", got, err)
+	}
+	resp, err = client.Actions.CreateOrUpdateRepoSecret(ctx, "aereal", "repo1", seal(t, key, "MY_SECRET", "v2"))
+	if got := statusCode(resp); err != nil || got != http.StatusNoContent {
+		t.Fatalf("update: status=%d err=%v", got, err)
+	}
+	if got, _ := srv.RepoSecret("aereal", "repo1", "MY_SECRET"); got != "v2" {
+		t.Errorf("repository secret = %q; want v2", got)
+	}
+
+	envKey, _, err := client.Actions.GetEnvPublicKey(ctx, int(repoID), "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Actions.CreateOrUpdateEnvSecret(ctx, int(repoID), "production", seal(t, envKey, "DEPLOY_KEY", "env")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.EnvSecret("aereal", "repo1", "production", "DEPLOY_KEY"); got != "env" {
+		t.Errorf("environment secret = %q; want env", got)
+	}
+
+	orgKey, _, err := client.Actions.GetOrgPublicKey(ctx, "myorg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Actions.CreateOrUpdateOrgSecret(ctx, "myorg", seal(t, orgKey, "ORG_SECRET", "org")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.OrgSecret("myorg", "ORG_SECRET"); got != "org" {
+		t.Errorf("organization secret = %q; want org", got)
+	}
+
+	if err := srv.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Actions.CreateOrUpdateRepoSecret(ctx, "aereal", "repo1", seal(t, key, "MY_SECRET", "stale"))
+	if got := statusCode(resp); err == nil || got != http.StatusUnprocessableEntity {
+		t.Errorf("sealed with the previous key: status=%d err=%v", got, err)
+	}
+
+	resp, err = client.Actions.DeleteRepoSecret(ctx, "aereal", "repo1", "MY_SECRET")
+	if got := statusCode(resp); err != nil || got != http.StatusNoContent {
+		t.Errorf("delete: status=%d err=%v", got, err)
+	}
+	if _, ok := srv.RepoSecret("aereal", "repo1", "MY_SECRET"); ok {
+		t.Error("the deleted secret remains")
+	}
+
+	_, resp, err = client.Actions.GetRepoPublicKey(ctx, "aereal", "unknown")
+	if got := statusCode(resp); err == nil || got != http.StatusNotFound {
+		t.Errorf("unknown repository: status=%d err=%v", got, err)
+	}
+	_, resp, err = newClient(t, srv, "wrong").Actions.GetRepoPublicKey(ctx, "aereal", "repo1")
+	if got := statusCode(resp); err == nil || got != http.StatusUnauthorized {
+		t.Errorf("wrong token: status=%d err=%v", got, err)
+	}
+}
+
+func TestServer_variables(t *testing.T) {
+	srv := ghfake.NewServer(t)
+	srv.AddRepository("aereal", "repo1")
+	srv.AddOrganization("myorg")
+	client := newClient(t, srv, "token")
+	ctx := t.Context()
+
+	if _, err := client.Actions.CreateRepoVariable(ctx, "aereal", "repo1", &github.ActionsVariable{Name: "REGION", Value: "us"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Actions.UpdateRepoVariable(ctx, "aereal", "repo1", &github.ActionsVariable{Name: "REGION", Value: "eu"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := client.Actions.GetRepoVariable(ctx, "aereal", "repo1", "REGION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "eu" {
+		t.Errorf("repository variable = %q; want eu", got.Value)
+	}
+	resp, err := client.Actions.CreateRepoVariable(ctx, "aereal", "repo1", &github.ActionsVariable{Name: "REGION", Value: "us"})
+	if got := statusCode(resp); err == nil || got != http.StatusConflict {
+		t.Errorf("duplicate variable: status=%d err=%v", got, err)
+	}
+	if _, err := client.Actions.CreateEnvVariable(ctx, "aereal", "repo1", "production", &github.ActionsVariable{Name: "TIER", Value: "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.EnvVariable("aereal", "repo1", "production", "TIER"); got != "prod" {
+		t.Errorf("environment variable = %q; want prod", got)
+	}
+	if _, err := client.Actions.CreateOrgVariable(ctx, "myorg", &github.ActionsVariable{Name: "SHARED", Value: "yes", Visibility: github.Ptr("all")}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.OrgVariable("myorg", "SHARED"); got != "yes" {
+		t.Errorf("organization variable = %q; want yes", got)
+	}
+}
+
+func TestServer_pagination(t *testing.T) {
+	srv := ghfake.NewServer(t, ghfake.WithPerPage(2))
+	srv.AddOrganization("myorg")
+	for _, name := range []string{"repo3", "repo1", "repo2", "repo4", "repo5"} {
+		srv.AddRepository("myorg", name)
+	}
+	srv.AddRepository("other", "repo6")
+	client := newClient(t, srv, "token")
+	ctx := t.Context()
+
+	var (
+		got  []string
+		opts = &github.RepositoryListByOrgOptions{}
+	)
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("too many pages")
+		}
+		repos, resp, err := client.Repositories.ListByOrg(ctx, "myorg", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range repos {
+			got = append(got, r.GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if diff := cmp.Diff([]string{"repo1", "repo2", "repo3", "repo4", "repo5"}, got); diff != "" {
+		t.Errorf("repositories (-want, +got):\n%s", diff)
+	}
+
+	key, _, err := client.Actions.GetRepoPublicKey(ctx, "myorg", "repo1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"C", "A", "B"} {
+		if _, err := client.Actions.CreateOrUpdateRepoSecret(ctx, "myorg", "repo1", seal(t, key, name, "v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secrets, resp, err := client.Actions.ListRepoSecrets(ctx, "myorg", "repo1", &github.ListOptions{Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets.TotalCount != 3 || len(secrets.Secrets) != 1 || secrets.Secrets[0].Name != "C" || resp.NextPage != 0 || resp.PrevPage != 1 {
+		t.Errorf("second page: total=%d secrets=%v next=%d prev=%d", secrets.TotalCount, secrets.Secrets, resp.NextPage, resp.PrevPage)
+	}
+}
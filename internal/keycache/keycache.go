@@ -0,0 +1,89 @@
+// Package keycache stores the public keys of GitHub Actions secrets on disk.
+package keycache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
+)
+
+// New returns the cache that keeps each key as a file under dir for ttl.
+func New(dir string, ttl time.Duration) *FileCache {
+	return &FileCache{dir: dir, ttl: ttl}
+}
+
+type FileCache struct {
+	dir string
+	ttl time.Duration
+}
+
+var _ usecases.PublicKeyCache = (*FileCache)(nil)
+
+type entry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	KeyID     string    `json:"key_id"`
+	Key       string    `json:"key"`
+}
+
+func (c *FileCache) Get(_ context.Context, scope string) (*github.PublicKey, error) {
+	b, err := os.ReadFile(c.path(scope))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		// a broken entry is as good as a missing one; it is overwritten by the next Put
+		return nil, nil //nolint:nilerr
+	}
+	if time.Now().Sub(e.FetchedAt) >= c.ttl || e.KeyID == "" || e.Key == "" {
+		return nil, nil
+	}
+	return &github.PublicKey{KeyID: &e.KeyID, Key: &e.Key}, nil
+}
+
+func (c *FileCache) Put(_ context.Context, scope string, key *github.PublicKey) error {
+	b, err := json.Marshal(entry{FetchedAt: time.Now(), KeyID: key.GetKeyID(), Key: key.GetKey()})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	// write to a temporary file and rename it so that concurrent runs never read a partial entry
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // already renamed on success
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(scope))
+}
+
+func (c *FileCache) Invalidate(_ context.Context, scope string) error {
+	err := os.Remove(c.path(scope))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (c *FileCache) path(scope string) string {
+	return filepath.Join(c.dir, url.PathEscape(scope)+".json")
+}
@@ -0,0 +1,94 @@
+package keycache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/keycache"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestFileCache(t *testing.T) {
+	key := &github.PublicKey{KeyID: ref("0xdeadbeaf"), Key: ref("a2V5")}
+	testCases := []struct {
+		prepare func(t *testing.T, c *keycache.FileCache, dir string)
+		want    *github.PublicKey
+		name    string
+		ttl     time.Duration
+	}{
+		{
+			name: "missing",
+			ttl:  time.Hour,
+		},
+		{
+			name: "hit",
+			ttl:  time.Hour,
+			prepare: func(t *testing.T, c *keycache.FileCache, _ string) {
+				if err := c.Put(t.Context(), "repos/aereal/myrepo", key); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: key,
+		},
+		{
+			name: "other scope",
+			ttl:  time.Hour,
+			prepare: func(t *testing.T, c *keycache.FileCache, _ string) {
+				if err := c.Put(t.Context(), "orgs/aereal", key); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "expired",
+			ttl:  0,
+			prepare: func(t *testing.T, c *keycache.FileCache, _ string) {
+				if err := c.Put(t.Context(), "repos/aereal/myrepo", key); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "invalidated",
+			ttl:  time.Hour,
+			prepare: func(t *testing.T, c *keycache.FileCache, _ string) {
+				if err := c.Put(t.Context(), "repos/aereal/myrepo", key); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Invalidate(t.Context(), "repos/aereal/myrepo"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "broken entry",
+			ttl:  time.Hour,
+			prepare: func(t *testing.T, _ *keycache.FileCache, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "repos%2Faereal%2Fmyrepo.json"), []byte("{"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := keycache.New(dir, tc.ttl)
+			if tc.prepare != nil {
+				tc.prepare(t, c, dir)
+			}
+			got, err := c.Get(t.Context(), "repos/aereal/myrepo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("key (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func ref[T any](t T) *T { return &t }
@@ -0,0 +1,146 @@
+package log
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// The environment variables giving the defaults of the logging options.
+const (
+	EnvLevel  = "REGISTER_GITHUB_SECRET_LOG_LEVEL"
+	EnvFormat = "REGISTER_GITHUB_SECRET_LOG_FORMAT"
+	EnvFile   = "REGISTER_GITHUB_SECRET_LOG_FILE"
+)
+
+// Format is how the log records are written.
+type Format string
+
+const (
+	// FormatAuto is FormatText on a terminal and FormatJSON otherwise.
+	FormatAuto Format = "auto"
+	// FormatText is the human-friendly form, colored on a terminal.
+	FormatText   Format = "text"
+	FormatJSON   Format = "json"
+	FormatLogfmt Format = "logfmt"
+)
+
+var _ flag.Value = (*Format)(nil)
+
+func (f *Format) String() string { return string(*f) }
+
+func (f *Format) Set(v string) error {
+	switch Format(v) {
+	case FormatAuto, FormatText, FormatJSON, FormatLogfmt:
+		*f = Format(v)
+		return nil
+	default:
+		return &UnknownFormatError{Format: v}
+	}
+}
+
+type UnknownFormatError struct {
+	Format string
+}
+
+func (e *UnknownFormatError) Error() string {
+	return fmt.Sprintf("unknown log format %q; must be one of text, json, logfmt or auto", e.Format)
+}
+
+func (e *UnknownFormatError) Is(other error) bool {
+	thatErr := new(UnknownFormatError)
+	if !errors.As(other, &thatErr) {
+		return false
+	}
+	return e.Format == thatErr.Format
+}
+
+func (e *UnknownFormatError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("format", e.Format))
+}
+
+// Config tells where and how the logs are written.
+type Config struct {
+	// Format is empty or FormatAuto to choose by whether the destination is a terminal.
+	Format Format
+	// File is the path the logs are appended to; empty means stderr.
+	File  string
+	Level slog.Level
+}
+
+type Option func(*options)
+
+type options struct {
+	level   slog.Leveler
+	format  Format
+	noColor bool
+}
+
+// WithLevel drops the records below the level; the default is slog.LevelInfo.
+func WithLevel(level slog.Leveler) Option {
+	return func(o *options) { o.level = level }
+}
+
+// WithFormat chooses the format; the default is FormatAuto.
+func WithFormat(format Format) Option {
+	return func(o *options) { o.format = format }
+}
+
+// WithoutColor keeps FormatText plain even on a terminal.
+func WithoutColor() Option {
+	return func(o *options) { o.noColor = true }
+}
+
+// Configure makes the default logger follow cfg and mask the secrets, which may be nil.
+// The returned function closes the log file; it must be called once the logger is no longer used.
+func Configure(cfg Config, secrets *Secrets) (func() error, error) {
+	var (
+		out       = os.Stderr
+		closeFile = func() error { return nil }
+	)
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open the log file: %w", err)
+		}
+		out, closeFile = f, f.Close
+	}
+	opts := []Option{WithLevel(cfg.Level), WithFormat(cfg.Format)}
+	// https://no-color.org/
+	if os.Getenv("NO_COLOR") != "" {
+		opts = append(opts, WithoutColor())
+	}
+	slog.SetDefault(NewLogger(out, secrets, opts...))
+	return closeFile, nil
+}
+
+func newHandler(out io.Writer, o *options) slog.Handler {
+	terminal := isTerminal(out)
+	format := o.format
+	if format == "" || format == FormatAuto {
+		format = FormatJSON
+		if terminal {
+			format = FormatText
+		}
+	}
+	switch format {
+	case FormatText:
+		return NewConsoleHandler(out, &ConsoleHandlerOptions{Level: o.level, Color: terminal && !o.noColor})
+	case FormatLogfmt:
+		return slog.NewTextHandler(out, &slog.HandlerOptions{Level: o.level})
+	default:
+		return slog.NewJSONHandler(out, &slog.HandlerOptions{Level: o.level})
+	}
+}
+
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
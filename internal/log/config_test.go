@@ -0,0 +1,100 @@
+package log_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewLogger_options(t *testing.T) {
+	timePattern := regexp.MustCompile(`(?m)^(time=\S+|\d{2}:\d{2}:\d{2}\.\d{3}) `)
+	testCases := []struct {
+		name string
+		want string
+		opts []log.Option
+	}{
+		{
+			name: "logfmt",
+			opts: []log.Option{log.WithFormat(log.FormatLogfmt)},
+			want: "level=INFO msg=info k=\"a b\"\n",
+		},
+		{
+			name: "logfmt at debug level",
+			opts: []log.Option{log.WithFormat(log.FormatLogfmt), log.WithLevel(slog.LevelDebug)},
+			want: "level=DEBUG msg=debug k=\"a b\"\nlevel=INFO msg=info k=\"a b\"\n",
+		},
+		{
+			name: "text is plain off a terminal",
+			opts: []log.Option{log.WithFormat(log.FormatText)},
+			want: "INFO  info k=\"a b\"\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			logger := log.NewLogger(out, nil, tc.opts...)
+			logger.Debug("debug", slog.String("k", "a b"))
+			logger.Info("info", slog.String("k", "a b"))
+			if diff := cmp.Diff(tc.want, timePattern.ReplaceAllString(out.String(), "")); diff != "" {
+				t.Errorf("output (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormat_Set(t *testing.T) {
+	testCases := []struct {
+		wantErr error
+		input   string
+		want    log.Format
+	}{
+		{input: "text", want: log.FormatText},
+		{input: "json", want: log.FormatJSON},
+		{input: "logfmt", want: log.FormatLogfmt},
+		{input: "auto", want: log.FormatAuto},
+		{input: "yaml", wantErr: &log.UnknownFormatError{Format: "yaml"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var got log.Format
+			gotErr := got.Set(tc.input)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("format: want=%q got=%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigure_file(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	path := filepath.Join(t.TempDir(), "run.log")
+	secrets := new(log.Secrets)
+	secrets.Add("s3cr3t")
+	closeLog, err := log.Configure(log.Config{File: path, Format: log.FormatLogfmt}, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("registered", slog.String("value", "s3cr3t"))
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "level=INFO msg=registered value=" + log.RedactedMarker + "\n"
+	if diff := cmp.Diff(want, regexp.MustCompile(`time=\S+ `).ReplaceAllString(string(b), "")); diff != "" {
+		t.Errorf("log file (-want, +got):\n%s", diff)
+	}
+}
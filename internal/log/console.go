@@ -0,0 +1,174 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiFaint   = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiMagenta = "\x1b[35m"
+)
+
+type ConsoleHandlerOptions struct {
+	// Level is the minimum level written; nil means slog.LevelInfo.
+	Level slog.Leveler
+	// Color decorates the records with ANSI escape sequences.
+	Color bool
+}
+
+// NewConsoleHandler returns the handler writing a record per line for humans to read:
+// the time, the level, the message and then the attributes as key=value, the ones in groups keyed by the dotted path.
+func NewConsoleHandler(out io.Writer, opts *ConsoleHandlerOptions) *ConsoleHandler {
+	h := &ConsoleHandler{out: out, mux: new(sync.Mutex)}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+type ConsoleHandler struct {
+	opts ConsoleHandlerOptions
+	out  io.Writer
+	mux  *sync.Mutex
+	// prefix is the dotted path of the groups opened by WithGroup.
+	prefix string
+	// attrs are the ones given by WithAttrs, already formatted.
+	attrs []byte
+}
+
+var _ slog.Handler = (*ConsoleHandler)(nil)
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := make([]byte, 0, 256)
+	if !record.Time.IsZero() {
+		buf = h.appendColored(buf, ansiFaint, record.Time.Format("15:04:05.000"))
+		buf = append(buf, ' ')
+	}
+	buf = h.appendColored(buf, levelColor(record.Level), fmt.Sprintf("%-5s", record.Level.String()))
+	buf = append(buf, ' ')
+	buf = append(buf, record.Message...)
+	buf = append(buf, h.attrs...)
+	record.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, h.prefix, a)
+		return true
+	})
+	buf = append(buf, '\n')
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	_, err := h.out.Write(buf)
+	return err
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	newHandler := *h
+	newHandler.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		newHandler.attrs = h.appendAttr(newHandler.attrs, h.prefix, a)
+	}
+	return &newHandler
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	newHandler := *h
+	newHandler.prefix = h.prefix + name + "."
+	return &newHandler
+}
+
+func (h *ConsoleHandler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			buf = h.appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+	buf = append(buf, ' ')
+	buf = h.appendColored(buf, ansiFaint, prefix+a.Key+"=")
+	return append(buf, consoleValue(a.Value)...)
+}
+
+func (h *ConsoleHandler) appendColored(buf []byte, color, s string) []byte {
+	if !h.opts.Color {
+		return append(buf, s...)
+	}
+	buf = append(buf, color...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	default:
+		return ansiMagenta
+	}
+}
+
+func consoleValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return quoteIfNeeded(v.String())
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return quoteIfNeeded(err.Error())
+		}
+		// the structured values such as the error chain are easier to read as JSON than as Go syntax
+		if b, err := json.Marshal(v.Any()); err == nil {
+			return string(b)
+		}
+		return quoteIfNeeded(fmt.Sprintf("%+v", v.Any()))
+	default:
+		return v.String()
+	}
+}
+
+func quoteIfNeeded(s string) string {
+	needsQuote := s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '=' || r == '"' || !unicode.IsPrint(r)
+	})
+	if needsQuote {
+		return strconv.Quote(s)
+	}
+	return s
+}
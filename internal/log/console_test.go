@@ -0,0 +1,99 @@
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	at := time.Date(2025, time.March, 1, 12, 34, 56, 789_000_000, time.UTC)
+	testCases := []struct {
+		runLog func(h slog.Handler) error
+		name   string
+		want   string
+		opts   log.ConsoleHandlerOptions
+	}{
+		{
+			name: "attributes",
+			runLog: func(h slog.Handler) error {
+				r := slog.NewRecord(at, slog.LevelInfo, "registered", 0)
+				r.AddAttrs(slog.String("repo", "aereal/repo1"), slog.Int("attempts", 2), slog.String("reason", "a b"), slog.String("empty", ""))
+				return h.Handle(t.Context(), r)
+			},
+			want: "12:34:56.789 INFO  registered repo=aereal/repo1 attempts=2 reason=\"a b\" empty=\"\"\n",
+		},
+		{
+			name: "zero time",
+			runLog: func(h slog.Handler) error {
+				return h.Handle(t.Context(), slog.NewRecord(time.Time{}, slog.LevelWarn, "msg", 0))
+			},
+			want: "WARN  msg\n",
+		},
+		{
+			name: "groups",
+			runLog: func(h slog.Handler) error {
+				r := slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0)
+				r.AddAttrs(slog.Group("g", slog.String("b", "2"), slog.Group("", slog.String("c", "3"))), slog.Group("empty"))
+				return h.WithAttrs([]slog.Attr{slog.String("a", "1")}).WithGroup("outer").Handle(t.Context(), r)
+			},
+			want: "INFO  msg a=1 outer.g.b=2 outer.g.c=3\n",
+		},
+		{
+			name: "error and structured values",
+			runLog: func(h slog.Handler) error {
+				r := slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+				r.AddAttrs(slog.Any("err", errors.New("oops")), slog.Any("names", []string{"A", "B"}))
+				return h.Handle(t.Context(), r)
+			},
+			want: "ERROR failed err=oops names=[\"A\",\"B\"]\n",
+		},
+		{
+			name: "colored",
+			runLog: func(h slog.Handler) error {
+				r := slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+				r.AddAttrs(slog.String("k", "v"))
+				return h.Handle(t.Context(), r)
+			},
+			opts: log.ConsoleHandlerOptions{Color: true},
+			want: "\x1b[31mERROR\x1b[0m failed \x1b[2mk=\x1b[0mv\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			if err := tc.runLog(log.NewConsoleHandler(out, &tc.opts)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("output (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConsoleHandler_Enabled(t *testing.T) {
+	testCases := []struct {
+		level slog.Leveler
+		name  string
+		want  []bool
+	}{
+		{name: "default", want: []bool{false, true, true}},
+		{name: "debug", level: slog.LevelDebug, want: []bool{true, true, true}},
+		{name: "warn", level: slog.LevelWarn, want: []bool{false, false, true}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := log.NewConsoleHandler(new(bytes.Buffer), &log.ConsoleHandlerOptions{Level: tc.level})
+			got := []bool{h.Enabled(t.Context(), slog.LevelDebug), h.Enabled(t.Context(), slog.LevelInfo), h.Enabled(t.Context(), slog.LevelWarn)}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("enabled (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"maps"
+	"net/http"
 	"testing"
 	"testing/slogtest"
 
 	"github.com/aereal/register-github-secret/internal/log"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-github/v69/github"
 )
 
 func TestHandler_error_replace(t *testing.T) {
@@ -48,6 +51,108 @@ func TestHandler_error_replace(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:   "with wrapped error",
+			runLog: func(l *slog.Logger) { l.Info("msg", log.AttrError(fmt.Errorf("outer: %w", errors.New("oops")))) },
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "outer: oops",
+						"error.type":    "*fmt.wrapError",
+						"error.chain": []any{
+							map[string]any{"type": "*errors.errorString", "message": "oops"},
+						},
+					},
+				}
+			},
+		},
+		{
+			name: "with joined errors",
+			runLog: func(l *slog.Logger) {
+				l.Info("msg", log.AttrError(errors.Join(errors.New("a"), fmt.Errorf("b: %w", &fieldsError{Repo: "repo1"}))))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "a\nb: repo1 is broken",
+						"error.type":    "*errors.joinError",
+						"error.chain": []any{
+							map[string]any{"type": "*errors.errorString", "message": "a"},
+							map[string]any{
+								"type":    "*fmt.wrapError",
+								"message": "b: repo1 is broken",
+								"causes": []any{
+									map[string]any{"type": "*log_test.fieldsError", "message": "repo1 is broken", "fields": map[string]any{"repo": "repo1"}},
+								},
+							},
+						},
+					},
+				}
+			},
+		},
+		{
+			name:   "with typed fields",
+			runLog: func(l *slog.Logger) { l.Info("msg", log.AttrError(&fieldsError{Repo: "repo1"})) },
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "repo1 is broken",
+						"error.type":    "*log_test.fieldsError",
+						"error.fields":  map[string]any{"repo": "repo1"},
+					},
+				}
+			},
+		},
+		{
+			name: "with GitHub API error",
+			runLog: func(l *slog.Logger) {
+				req, _ := http.NewRequest(http.MethodPut, "https://api.github.com/repos/aereal/repo1/actions/secrets/MY_SECRET", nil)
+				resp := &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{"X-Github-Request-Id": {"ABCD:1234"}}, Request: req}
+				errResp := &github.ErrorResponse{Response: resp, Message: "Resource not accessible by integration", DocumentationURL: "https://docs.github.com/rest"}
+				l.Info("msg", log.AttrError(fmt.Errorf("register: %w", errResp)))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "register: PUT https://api.github.com/repos/aereal/repo1/actions/secrets/MY_SECRET: 403 Resource not accessible by integration []",
+						"error.type":    "*fmt.wrapError",
+						"error.chain": []any{
+							map[string]any{"type": "*github.ErrorResponse", "message": "PUT https://api.github.com/repos/aereal/repo1/actions/secrets/MY_SECRET: 403 Resource not accessible by integration []"},
+						},
+						"error.github": map[string]any{
+							"message":           "Resource not accessible by integration",
+							"status":            float64(http.StatusForbidden),
+							"request_id":        "ABCD:1234",
+							"method":            http.MethodPut,
+							"url":               "https://api.github.com/repos/aereal/repo1/actions/secrets/MY_SECRET",
+							"documentation_url": "https://docs.github.com/rest",
+						},
+					},
+				}
+			},
+		},
+		{
+			name:   "with error in preformatted attributes",
+			runLog: func(l *slog.Logger) { l.With(log.AttrError(errors.New("oops"))).Info("msg") },
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "oops",
+						"error.type":    "*errors.errorString",
+					},
+				}
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -67,6 +172,16 @@ func TestHandler_error_replace(t *testing.T) {
 	}
 }
 
+type fieldsError struct {
+	Repo string
+}
+
+func (e *fieldsError) Error() string { return e.Repo + " is broken" }
+
+func (e *fieldsError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("repo", e.Repo))
+}
+
 func ignoreTimeAttribute() cmp.Option {
 	return cmpopts.IgnoreMapEntries(func(key string, _ any) bool {
 		return key == slog.TimeKey
@@ -2,11 +2,13 @@ package log
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"slices"
+
+	"github.com/google/go-github/v69/github"
 )
 
 const originalKeyError = "error"
@@ -15,19 +17,31 @@ func AttrError(err error) slog.Attr {
 	return slog.Attr{Key: originalKeyError, Value: slog.AnyValue(err)}
 }
 
-func Setup() {
-	slog.SetDefault(NewLogger(os.Stderr))
+// Setup makes the default logger write to stderr in FormatAuto and mask the secrets, which may be nil.
+func Setup(secrets *Secrets) {
+	slog.SetDefault(NewLogger(os.Stderr, secrets))
 }
 
-func NewLogger(out io.Writer) *slog.Logger {
-	h := slog.NewJSONHandler(out, &slog.HandlerOptions{})
-	return slog.New(h)
+func NewLogger(out io.Writer, secrets *Secrets, opts ...Option) *slog.Logger {
+	o := &options{}
+	for _, f := range opts {
+		f(o)
+	}
+	h := NewTraceContextHandler(newHandler(out, o))
+	if secrets != nil {
+		h = NewRedactingHandler(h, secrets)
+	}
+	// the errors are expanded before the redaction so that the secrets in their messages are masked
+	return slog.New(NewErrorAttributeTransformer(h))
 }
 
 func NewErrorAttributeTransformer(h slog.Handler) slog.Handler {
 	return &ErrorAttributeTransformer{h}
 }
 
+// ErrorAttributeTransformer expands the error given by AttrError into attributes describing it:
+// error.message and error.type of the error itself, error.fields if it is a slog.LogValuer,
+// error.chain of the errors it wraps including every branch of errors.Join, and error.github for the GitHub API errors in the chain.
 type ErrorAttributeTransformer struct {
 	slog.Handler
 }
@@ -35,32 +49,127 @@ type ErrorAttributeTransformer struct {
 var _ slog.Handler = (*ErrorAttributeTransformer)(nil)
 
 func (h *ErrorAttributeTransformer) Handle(ctx context.Context, record slog.Record) error {
-	attrs := make([]slog.Attr, record.NumAttrs())
-	idx := -1
-	foundIndex := -1
+	newRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
 	record.Attrs(func(a slog.Attr) bool {
-		idx++
-		attrs[idx] = a
-		if a.Key == originalKeyError && a.Value.Kind() == slog.KindAny {
-			foundIndex = idx
+		if err, ok := attrError(a); ok {
+			newRecord.AddAttrs(errorAttrs(err)...)
+		} else {
+			newRecord.AddAttrs(a)
 		}
-		return false
+		return true
 	})
-	if foundIndex < 0 {
-		return h.Handler.Handle(ctx, record)
+	return h.Handler.Handle(ctx, newRecord)
+}
+
+func (h *ErrorAttributeTransformer) WithAttrs(attrs []slog.Attr) slog.Handler {
+	expanded := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if err, ok := attrError(a); ok {
+			expanded = append(expanded, errorAttrs(err)...)
+		} else {
+			expanded = append(expanded, a)
+		}
+	}
+	return &ErrorAttributeTransformer{h.Handler.WithAttrs(expanded)}
+}
+
+func (h *ErrorAttributeTransformer) WithGroup(name string) slog.Handler {
+	return &ErrorAttributeTransformer{h.Handler.WithGroup(name)}
+}
+
+func attrError(a slog.Attr) (error, bool) {
+	if a.Key != originalKeyError {
+		return nil, false
+	}
+	switch a.Value.Kind() {
+	case slog.KindAny, slog.KindLogValuer:
+		err, ok := a.Value.Any().(error)
+		return err, ok && err != nil
+	default:
+		return nil, false
+	}
+}
+
+func errorAttrs(err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("error.message", err.Error()),
+		slog.String("error.type", fmt.Sprintf("%T", err)),
+	}
+	if fields := errorFields(err); len(fields) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "error.fields", Value: slog.GroupValue(fields...)})
+	}
+	if chain := errorChain(err); len(chain) > 0 {
+		attrs = append(attrs, slog.Any("error.chain", chain))
 	}
+	if errResp := new(github.ErrorResponse); errors.As(err, &errResp) {
+		attrs = append(attrs, slog.Attr{Key: "error.github", Value: slog.GroupValue(gitHubErrorFields(errResp)...)})
+	}
+	return attrs
+}
 
-	orig := attrs[foundIndex]
-	valErr, ok := orig.Value.Any().(error)
+// errorFields returns the fields of the error if it describes itself as a slog.LogValuer.
+func errorFields(err error) []slog.Attr {
+	lv, ok := err.(slog.LogValuer) //nolint:errorlint // only the error itself is looked at
 	if !ok {
-		return h.Handler.Handle(ctx, record)
+		return nil
+	}
+	v := lv.LogValue().Resolve()
+	if v.Kind() == slog.KindGroup {
+		return v.Group()
 	}
+	return []slog.Attr{{Key: "value", Value: v}}
+}
 
-	newAttrs := slices.Clone(attrs)
-	newAttrs = slices.Delete(newAttrs, foundIndex, foundIndex+1)
-	newAttrs = slices.Grow(newAttrs, 1)
-	newAttrs = slices.Insert(newAttrs, foundIndex, slog.String("error.message", valErr.Error()), slog.String("error.type", fmt.Sprintf("%T", valErr)))
-	newRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
-	newRecord.AddAttrs(newAttrs...)
-	return h.Handler.Handle(ctx, newRecord)
+// ErrorLink is an error found by unwrapping another one.
+type ErrorLink struct {
+	Fields  map[string]any `json:"fields,omitempty"`
+	Type    string         `json:"type"`
+	Message string         `json:"message"`
+	// Causes are the errors this one wraps; errors.Join and fmt.Errorf with several %w have more than one.
+	Causes []ErrorLink `json:"causes,omitempty"`
+}
+
+// errorChain returns the errors wrapped by err, each with the ones it wraps in turn.
+func errorChain(err error) []ErrorLink {
+	var causes []error
+	switch x := err.(type) { //nolint:errorlint // unwrapping by hand to visit every branch
+	case interface{ Unwrap() error }:
+		if cause := x.Unwrap(); cause != nil {
+			causes = []error{cause}
+		}
+	case interface{ Unwrap() []error }:
+		causes = x.Unwrap()
+	}
+	links := make([]ErrorLink, 0, len(causes))
+	for _, cause := range causes {
+		if cause == nil {
+			continue
+		}
+		link := ErrorLink{Type: fmt.Sprintf("%T", cause), Message: cause.Error(), Causes: errorChain(cause)}
+		if fields := errorFields(cause); len(fields) > 0 {
+			link.Fields = make(map[string]any, len(fields))
+			for _, f := range fields {
+				link.Fields[f.Key] = f.Value.Resolve().Any()
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func gitHubErrorFields(errResp *github.ErrorResponse) []slog.Attr {
+	attrs := []slog.Attr{slog.String("message", errResp.Message)}
+	if resp := errResp.Response; resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if requestID := resp.Header.Get("X-GitHub-Request-Id"); requestID != "" {
+			attrs = append(attrs, slog.String("request_id", requestID))
+		}
+		if req := resp.Request; req != nil && req.URL != nil {
+			attrs = append(attrs, slog.String("method", req.Method), slog.String("url", req.URL.String()))
+		}
+	}
+	if errResp.DocumentationURL != "" {
+		attrs = append(attrs, slog.String("documentation_url", errResp.DocumentationURL))
+	}
+	return attrs
 }
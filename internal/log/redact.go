@@ -0,0 +1,162 @@
+package log
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RedactedMarker replaces the secret values found in the logs.
+const RedactedMarker = "[REDACTED]"
+
+// MinMaskedLength is the length from which a secret value is masked wherever it appears.
+// The shorter values would match inside unrelated text, which the mask would corrupt and reveal the value by,
+// so they are masked only where they make up a whole string.
+const MinMaskedLength = 4
+
+// Secrets is the set of values that must never appear in the logs.
+// The values are added as they become known, such as once the command line is parsed or a token is resolved.
+type Secrets struct {
+	replacer *strings.Replacer
+	// short are the values shorter than MinMaskedLength.
+	short  []string
+	values []string
+	mux    sync.RWMutex
+}
+
+// Add registers the values; empty ones are ignored. It does nothing on a nil Secrets.
+func (s *Secrets) Add(values ...string) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, v := range values {
+		switch {
+		case v == "":
+		case len(v) < MinMaskedLength:
+			if !slices.Contains(s.short, v) {
+				s.short = append(s.short, v)
+			}
+		case !slices.Contains(s.values, v):
+			s.values = append(s.values, v)
+		}
+	}
+	// the longer values go first so that a value containing another is masked as a whole
+	slices.SortFunc(s.values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	pairs := make([]string, 0, len(s.values)*2)
+	for _, v := range s.values {
+		pairs = append(pairs, v, RedactedMarker)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+// redact returns str with the secret values masked, and whether any was found.
+func (s *Secrets) redact(str string) (string, bool) {
+	s.mux.RLock()
+	replacer := s.replacer
+	isShort := slices.Contains(s.short, str)
+	s.mux.RUnlock()
+	if isShort {
+		return RedactedMarker, true
+	}
+	if replacer == nil {
+		return str, false
+	}
+	redacted := replacer.Replace(str)
+	return redacted, redacted != str
+}
+
+func (s *Secrets) redactAttrs(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, s.redactAttr(a))
+	}
+	return redacted
+}
+
+// redactAttr masks the secret values in the attribute; the errors and the other values holding any are turned into masked strings.
+func (s *Secrets) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if v, ok := s.redact(a.Value.String()); ok {
+			a.Value = slog.StringValue(v)
+		}
+	case slog.KindGroup:
+		a.Value = slog.GroupValue(s.redactAttrs(a.Value.Group())...)
+	case slog.KindAny:
+		if links, isChain := a.Value.Any().([]ErrorLink); isChain {
+			a.Value = slog.AnyValue(s.redactErrorLinks(links))
+			break
+		}
+		if v, ok := s.redactAny(a.Value.Any()); ok {
+			a.Value = slog.StringValue(v)
+		}
+	default:
+	}
+	return a
+}
+
+func (s *Secrets) redactAny(v any) (string, bool) {
+	if err, isErr := v.(error); isErr {
+		return s.redact(err.Error())
+	}
+	return s.redact(fmt.Sprintf("%+v", v))
+}
+
+// redactErrorLinks masks the chain of errors keeping its structure.
+func (s *Secrets) redactErrorLinks(links []ErrorLink) []ErrorLink {
+	redacted := make([]ErrorLink, 0, len(links))
+	for _, link := range links {
+		link.Message, _ = s.redact(link.Message)
+		if len(link.Fields) > 0 {
+			fields := make(map[string]any, len(link.Fields))
+			for k, v := range link.Fields {
+				if masked, ok := s.redactAny(v); ok {
+					v = masked
+				}
+				fields[k] = v
+			}
+			link.Fields = fields
+		}
+		link.Causes = s.redactErrorLinks(link.Causes)
+		redacted = append(redacted, link)
+	}
+	return redacted
+}
+
+func NewRedactingHandler(h slog.Handler, secrets *Secrets) slog.Handler {
+	return &RedactingHandler{Handler: h, secrets: secrets}
+}
+
+// RedactingHandler masks the registered secret values in the messages and the attributes, including the messages of errors.
+type RedactingHandler struct {
+	slog.Handler
+	secrets *Secrets
+}
+
+var _ slog.Handler = (*RedactingHandler)(nil)
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	msg, _ := h.secrets.redact(record.Message)
+	newRecord := slog.NewRecord(record.Time, record.Level, msg, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		newRecord.AddAttrs(h.secrets.redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, newRecord)
+}
+
+// WithAttrs masks the attributes now, so the values registered later are not masked in them.
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RedactingHandler{Handler: h.Handler.WithAttrs(h.secrets.redactAttrs(attrs)), secrets: h.secrets}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{Handler: h.Handler.WithGroup(name), secrets: h.secrets}
+}
@@ -0,0 +1,169 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+type secretHolder struct{ Token string }
+
+type secretValuer struct{}
+
+func (secretValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("token", "ghp_token"), slog.Int("n", 1))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	testCases := []struct {
+		runLog func(l *slog.Logger)
+		want   func() []map[string]any
+		name   string
+	}{
+		{
+			name: "message and string attribute",
+			runLog: func(l *slog.Logger) {
+				l.Info("set s3cret!", slog.String("value", "xs3cretx"), slog.String("other", "plain"))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{slog.LevelKey: "INFO", slog.MessageKey: "set [REDACTED]!", "value": "x[REDACTED]x", "other": "plain"},
+				}
+			},
+		},
+		{
+			name: "error",
+			runLog: func(l *slog.Logger) {
+				l.Info("msg", slog.Any("error", fmt.Errorf("wrapped: %w", errors.New("bad token ghp_token"))), slog.Any("clean", errors.New("oops")))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"error.message": "wrapped: bad token [REDACTED]",
+						"error.type":    "*fmt.wrapError",
+						"error.chain":   []any{map[string]any{"type": "*errors.errorString", "message": "bad token [REDACTED]"}},
+						"clean":         "oops",
+					},
+				}
+			},
+		},
+		{
+			name: "group and LogValuer",
+			runLog: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("req", slog.String("auth", "Bearer ghp_token")), slog.Any("valuer", secretValuer{}))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{
+						slog.LevelKey:   "INFO",
+						slog.MessageKey: "msg",
+						"req":           map[string]any{"auth": "Bearer [REDACTED]"},
+						"valuer":        map[string]any{"token": "[REDACTED]", "n": float64(1)},
+					},
+				}
+			},
+		},
+		{
+			name:   "arbitrary value",
+			runLog: func(l *slog.Logger) { l.Info("msg", slog.Any("holder", secretHolder{Token: "ghp_token"})) },
+			want: func() []map[string]any {
+				return []map[string]any{
+					{slog.LevelKey: "INFO", slog.MessageKey: "msg", "holder": "{Token:[REDACTED]}"},
+				}
+			},
+		},
+		{
+			name: "WithAttrs",
+			runLog: func(l *slog.Logger) {
+				l.With(slog.String("token", "ghp_token")).WithGroup("g").Info("msg", slog.String("v", "s3cret"))
+			},
+			want: func() []map[string]any {
+				return []map[string]any{
+					{slog.LevelKey: "INFO", slog.MessageKey: "msg", "token": "[REDACTED]", "g": map[string]any{"v": "[REDACTED]"}},
+				}
+			},
+		},
+		{
+			name:   "longer value first",
+			runLog: func(l *slog.Logger) { l.Info("ghp_token_long") },
+			want: func() []map[string]any {
+				return []map[string]any{
+					{slog.LevelKey: "INFO", slog.MessageKey: "[REDACTED]"},
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			secrets := new(log.Secrets)
+			secrets.Add("s3cret", "ghp_token", "", "ghp_token_long")
+			out := new(bytes.Buffer)
+			logger := log.NewLogger(out, secrets)
+			tc.runLog(logger)
+			got, err := collectLogEntries(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want(), got, ignoreTimeAttribute()); diff != "" {
+				t.Errorf("result (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedactingHandler_shortValue(t *testing.T) {
+	secrets := new(log.Secrets)
+	secrets.Add("y")
+	out := new(bytes.Buffer)
+	log.NewLogger(out, secrets).Info("locate the public key cache", slog.String("value", "y"), slog.String("path", "/home/y/cache"))
+	got, err := collectLogEntries(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{slog.LevelKey: "INFO", slog.MessageKey: "locate the public key cache", "value": "[REDACTED]", "path": "/home/y/cache"},
+	}
+	if diff := cmp.Diff(want, got, ignoreTimeAttribute()); diff != "" {
+		t.Errorf("result (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRedactingHandler_slogtest(t *testing.T) {
+	out := new(bytes.Buffer)
+	secrets := new(log.Secrets)
+	secrets.Add("s3cret")
+	newHandler := func(_ *testing.T) slog.Handler {
+		out.Reset()
+		return log.NewRedactingHandler(slog.NewJSONHandler(out, &slog.HandlerOptions{}), secrets)
+	}
+	newResult := func(_ *testing.T) map[string]any {
+		ret := map[string]any{}
+		for {
+			line, err := out.ReadBytes('\n')
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := map[string]any{}
+			if err := json.Unmarshal(line, &m); err != nil {
+				t.Fatal(err)
+			}
+			maps.Insert(ret, maps.All(m))
+		}
+		return ret
+	}
+	slogtest.Run(t, newHandler, newResult)
+}
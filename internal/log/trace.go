@@ -0,0 +1,36 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTraceContextHandler returns the handler adding trace_id and span_id of the span in the context to each record,
+// so that the logs are correlated with the traces.
+func NewTraceContextHandler(h slog.Handler) slog.Handler {
+	return &TraceContextHandler{h}
+}
+
+type TraceContextHandler struct {
+	slog.Handler
+}
+
+var _ slog.Handler = (*TraceContextHandler)(nil)
+
+func (h *TraceContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record = record.Clone()
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *TraceContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &TraceContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *TraceContextHandler) WithGroup(name string) slog.Handler {
+	return &TraceContextHandler{h.Handler.WithGroup(name)}
+}
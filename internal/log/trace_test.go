@@ -0,0 +1,42 @@
+package log_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextHandler(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanID:  trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	})
+	out := new(bytes.Buffer)
+	logger := slog.New(log.NewTraceContextHandler(slog.NewJSONHandler(out, &slog.HandlerOptions{})))
+	logger.InfoContext(trace.ContextWithSpanContext(t.Context(), sc), "in a span", slog.String("k", "v"))
+	logger.InfoContext(t.Context(), "out of spans")
+	got, err := collectLogEntries(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{
+			slog.LevelKey:   "INFO",
+			slog.MessageKey: "in a span",
+			"k":             "v",
+			"trace_id":      "0102030405060708090a0b0c0d0e0f10",
+			"span_id":       "0102030405060708",
+		},
+		{
+			slog.LevelKey:   "INFO",
+			slog.MessageKey: "out of spans",
+		},
+	}
+	if diff := cmp.Diff(want, got, ignoreTimeAttribute()); diff != "" {
+		t.Errorf("result (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,127 @@
+// Package metrics collects the metrics of a run and writes them in the Prometheus text format,
+// to be picked up by the textfile collector of node_exporter.
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "register_github_secret"
+
+// The results a secret is counted under.
+const (
+	ResultWritten = "written"
+	ResultSkipped = "skipped"
+	ResultFailed  = "failed"
+)
+
+var (
+	apiDurationBuckets   = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	rateLimitWaitBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// New returns the empty Run.
+func New() *Run {
+	r := &Run{
+		registry: prometheus.NewRegistry(),
+		secrets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "secrets_total",
+			Help:      "Secrets processed by the result.",
+		}, []string{"host", "owner", "result"}),
+		apiCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_calls_total",
+			Help:      "GitHub API calls by the HTTP status; 0 means no response was received.",
+		}, []string{"operation", "status"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "api_call_duration_seconds",
+			Help:      "Latency of the GitHub API calls.",
+			Buckets:   apiDurationBuckets,
+		}, []string{"operation"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_retries_total",
+			Help:      "GitHub API calls retried after a transient failure.",
+		}, []string{"operation"}),
+		rateLimitWaits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_wait_seconds",
+			Help:      "Time waited for the rate limit before retrying a GitHub API call.",
+			Buckets:   rateLimitWaitBuckets,
+		}, []string{"operation"}),
+		runDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of the last run.",
+		}, []string{"command"}),
+		runSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "run_success",
+			Help:      "Whether the last run succeeded (1) or not (0).",
+		}, []string{"command"}),
+		runTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix time the last run finished at.",
+		}, []string{"command"}),
+	}
+	r.registry.MustRegister(r.secrets, r.apiCalls, r.apiDuration, r.retries, r.rateLimitWaits, r.runDuration, r.runSuccess, r.runTimestamp)
+	return r
+}
+
+// Run is safe for concurrent use.
+type Run struct {
+	registry       *prometheus.Registry
+	secrets        *prometheus.CounterVec
+	apiCalls       *prometheus.CounterVec
+	apiDuration    *prometheus.HistogramVec
+	retries        *prometheus.CounterVec
+	rateLimitWaits *prometheus.HistogramVec
+	runDuration    *prometheus.GaugeVec
+	runSuccess     *prometheus.GaugeVec
+	runTimestamp   *prometheus.GaugeVec
+}
+
+// CountSecret counts a secret processed on the repository of the owner on the host; result is one of ResultWritten, ResultSkipped and ResultFailed.
+func (r *Run) CountSecret(host, owner, result string) {
+	r.secrets.WithLabelValues(host, owner, result).Inc()
+}
+
+func (r *Run) ObserveAPICall(operation string, status int, elapsed time.Duration) {
+	r.apiCalls.WithLabelValues(operation, strconv.Itoa(status)).Inc()
+	r.apiDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+func (r *Run) CountRetry(operation string) {
+	r.retries.WithLabelValues(operation).Inc()
+}
+
+func (r *Run) ObserveRateLimitWait(operation string, wait time.Duration) {
+	r.rateLimitWaits.WithLabelValues(operation).Observe(wait.Seconds())
+}
+
+// ObserveRun records how the run of the command ended.
+func (r *Run) ObserveRun(command string, finishedAt time.Time, elapsed time.Duration, succeeded bool) {
+	success := 0.0
+	if succeeded {
+		success = 1
+	}
+	r.runDuration.WithLabelValues(command).Set(elapsed.Seconds())
+	r.runSuccess.WithLabelValues(command).Set(success)
+	r.runTimestamp.WithLabelValues(command).Set(float64(finishedAt.UnixMilli()) / 1000)
+}
+
+// WriteFile replaces the file at path with the metrics at once, so that the collector never reads a partial file.
+// The metrics without samples are left out.
+func (r *Run) WriteFile(path string) error {
+	if err := prometheus.WriteToTextfile(path, r.registry); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	return nil
+}
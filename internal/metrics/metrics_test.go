@@ -0,0 +1,99 @@
+package metrics_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/metrics"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun_WriteFile(t *testing.T) {
+	r := metrics.New()
+	r.CountSecret("github.com", "aereal", metrics.ResultWritten)
+	r.CountSecret("github.com", "aereal", metrics.ResultWritten)
+	r.CountSecret("ghe.example.com", "aereal", metrics.ResultFailed)
+	r.ObserveAPICall("GetRepoPublicKey", 502, 200*time.Millisecond)
+	r.ObserveAPICall("GetRepoPublicKey", 200, 3*time.Second)
+	r.CountRetry("GetRepoPublicKey")
+	r.ObserveRun("register", time.Unix(1700000000, 500_000_000), 1500*time.Millisecond, false)
+	path := filepath.Join(t.TempDir(), "register_github_secret.prom")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP register_github_secret_api_call_duration_seconds Latency of the GitHub API calls.
+# TYPE register_github_secret_api_call_duration_seconds histogram
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="0.05"} 0
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="0.1"} 0
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="0.25"} 1
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="0.5"} 1
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="1"} 1
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="2.5"} 1
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="5"} 2
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="10"} 2
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="30"} 2
+register_github_secret_api_call_duration_seconds_bucket{operation="GetRepoPublicKey",le="+Inf"} 2
+register_github_secret_api_call_duration_seconds_sum{operation="GetRepoPublicKey"} 3.2
+register_github_secret_api_call_duration_seconds_count{operation="GetRepoPublicKey"} 2
+# HELP register_github_secret_api_calls_total GitHub API calls by the HTTP status; 0 means no response was received.
+# TYPE register_github_secret_api_calls_total counter
+register_github_secret_api_calls_total{operation="GetRepoPublicKey",status="200"} 1
+register_github_secret_api_calls_total{operation="GetRepoPublicKey",status="502"} 1
+# HELP register_github_secret_api_retries_total GitHub API calls retried after a transient failure.
+# TYPE register_github_secret_api_retries_total counter
+register_github_secret_api_retries_total{operation="GetRepoPublicKey"} 1
+# HELP register_github_secret_last_run_timestamp_seconds Unix time the last run finished at.
+# TYPE register_github_secret_last_run_timestamp_seconds gauge
+register_github_secret_last_run_timestamp_seconds{command="register"} 1.7000000005e+09
+# HELP register_github_secret_run_duration_seconds Duration of the last run.
+# TYPE register_github_secret_run_duration_seconds gauge
+register_github_secret_run_duration_seconds{command="register"} 1.5
+# HELP register_github_secret_run_success Whether the last run succeeded (1) or not (0).
+# TYPE register_github_secret_run_success gauge
+register_github_secret_run_success{command="register"} 0
+# HELP register_github_secret_secrets_total Secrets processed by the result.
+# TYPE register_github_secret_secrets_total counter
+register_github_secret_secrets_total{host="ghe.example.com",owner="aereal",result="failed"} 1
+register_github_secret_secrets_total{host="github.com",owner="aereal",result="written"} 2
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("output (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRun_WriteFile_replace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "register_github_secret.prom")
+	if err := os.WriteFile(path, []byte("stale\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := metrics.New()
+	r.CountSecret("github.com", "aereal", metrics.ResultSkipped)
+	if err := r.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `register_github_secret_secrets_total{host="github.com",owner="aereal",result="skipped"} 1`) || strings.Contains(string(got), "stale") {
+		t.Errorf("the file must be replaced with the metrics:\n%s", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("the temporary file must be removed: %v", entries)
+	}
+	if err := metrics.New().WriteFile(filepath.Join(dir, "missing", "out.prom")); err == nil {
+		t.Error("want an error for the missing directory")
+	}
+}
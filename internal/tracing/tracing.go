@@ -0,0 +1,156 @@
+// Package tracing installs the OpenTelemetry tracer provider the spans of a run are exported through.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ServiceName is the service.name of the spans unless OTEL_SERVICE_NAME tells otherwise.
+const ServiceName = "register-github-secret"
+
+// The environment variables giving the defaults of the tracing options.
+// EnvExporter is the one defined by OpenTelemetry; the OTLP exporter also reads the other OTEL_EXPORTER_OTLP_* ones.
+const (
+	EnvExporter = "OTEL_TRACES_EXPORTER"
+	EnvFile     = "REGISTER_GITHUB_SECRET_TRACE_FILE"
+)
+
+// Exporter is where the spans are sent.
+type Exporter string
+
+const (
+	ExporterNone Exporter = "none"
+	// ExporterOTLP sends the spans over OTLP/HTTP to the endpoint given by OTEL_EXPORTER_OTLP_ENDPOINT.
+	ExporterOTLP Exporter = "otlp"
+	// ExporterFile appends the spans to Config.File as JSON, one per line.
+	ExporterFile Exporter = "file"
+)
+
+var _ flag.Value = (*Exporter)(nil)
+
+func (e *Exporter) String() string { return string(*e) }
+
+func (e *Exporter) Set(v string) error {
+	switch Exporter(v) {
+	case ExporterNone, ExporterOTLP, ExporterFile:
+		*e = Exporter(v)
+		return nil
+	default:
+		return &UnknownExporterError{Exporter: v}
+	}
+}
+
+type UnknownExporterError struct {
+	Exporter string
+}
+
+func (e *UnknownExporterError) Error() string {
+	return fmt.Sprintf("unknown trace exporter %q; must be one of none, otlp or file", e.Exporter)
+}
+
+func (e *UnknownExporterError) Is(other error) bool {
+	thatErr := new(UnknownExporterError)
+	if !errors.As(other, &thatErr) {
+		return false
+	}
+	return e.Exporter == thatErr.Exporter
+}
+
+func (e *UnknownExporterError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("exporter", e.Exporter))
+}
+
+type Config struct {
+	// Exporter is empty or ExporterNone to leave the spans unrecorded.
+	Exporter Exporter
+	// File is the path the spans are appended to by ExporterFile.
+	File string
+}
+
+// ContextFromEnv returns ctx with the trace context taken from TRACEPARENT, TRACESTATE and BAGGAGE by the global propagator,
+// so that the run joins the trace of the process that started it.
+func ContextFromEnv(ctx context.Context) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, envCarrier{})
+}
+
+// envCarrier reads the fields of the trace context from the environment variables named after them in upper case.
+type envCarrier struct{}
+
+var _ propagation.TextMapCarrier = envCarrier{}
+
+func (envCarrier) Get(key string) string { return os.Getenv(strings.ToUpper(key)) }
+
+func (envCarrier) Set(string, string) {}
+
+func (envCarrier) Keys() []string {
+	var keys []string
+	for _, key := range []string{"traceparent", "tracestate", "baggage"} {
+		if _, ok := os.LookupEnv(strings.ToUpper(key)); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Setup makes the global tracer provider export the spans as cfg tells, and propagate the trace context in the W3C format.
+// The returned function flushes the spans and must be called before exiting.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	var (
+		exporter  sdktrace.SpanExporter
+		closeFile = func() error { return nil }
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		otlpExporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("create the OTLP trace exporter: %w", err)
+		}
+		exporter = otlpExporter
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open the trace file: %w", err)
+		}
+		closeFile = f.Close
+		fileExporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("create the trace file exporter: %w", err)
+		}
+		exporter = fileExporter
+	default:
+		return nil, &UnknownExporterError{Exporter: string(cfg.Exporter)}
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES are applied last so that they take precedence
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		_ = closeFile()
+		return nil, fmt.Errorf("describe the resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closeFile())
+	}, nil
+}
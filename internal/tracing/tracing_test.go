@@ -0,0 +1,132 @@
+package tracing_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExporter_Set(t *testing.T) {
+	testCases := []struct {
+		wantErr error
+		input   string
+		want    tracing.Exporter
+	}{
+		{input: "none", want: tracing.ExporterNone},
+		{input: "otlp", want: tracing.ExporterOTLP},
+		{input: "file", want: tracing.ExporterFile},
+		{input: "jaeger", wantErr: &tracing.UnknownExporterError{Exporter: "jaeger"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var got tracing.Exporter
+			gotErr := got.Set(tc.input)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("exporter: want=%q got=%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetup_file(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(defaultProvider) })
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := tracing.Setup(t.Context(), tracing.Config{Exporter: tracing.ExporterFile, File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := otel.GetTracerProvider().Tracer("test")
+	ctx, parent := tracer.Start(t.Context(), "run")
+	_, child := tracer.Start(ctx, "target")
+	child.End()
+	parent.End()
+	if err := shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type exportedSpan struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Resource    []struct {
+			Value struct{ Value any }
+			Key   string
+		}
+	}
+	var spans []exportedSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s exportedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("the spans must be written one per line: %v", err)
+		}
+		spans = append(spans, s)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans but got %d", len(spans))
+	}
+	if spans[0].Name != "target" || spans[1].Name != "run" {
+		t.Errorf("span names: %q, %q", spans[0].Name, spans[1].Name)
+	}
+	if spans[0].SpanContext.TraceID != spans[1].SpanContext.TraceID {
+		t.Errorf("the spans must share the trace: %s, %s", spans[0].SpanContext.TraceID, spans[1].SpanContext.TraceID)
+	}
+	var serviceName any
+	for _, kv := range spans[0].Resource {
+		if kv.Key == "service.name" {
+			serviceName = kv.Value.Value
+		}
+	}
+	if serviceName != tracing.ServiceName {
+		t.Errorf("service.name: want=%q got=%v", tracing.ServiceName, serviceName)
+	}
+}
+
+func TestSetup_none(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	shutdown, err := tracing.Setup(t.Context(), tracing.Config{Exporter: tracing.ExporterNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != defaultProvider {
+		t.Error("the tracer provider must be left as is")
+	}
+}
+
+func TestContextFromEnv(t *testing.T) {
+	defaultPropagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(defaultPropagator) })
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc := trace.SpanContextFromContext(tracing.ContextFromEnv(t.Context()))
+	if !sc.IsRemote() {
+		t.Error("want the remote span context")
+	}
+	if got := sc.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID: got %s", got)
+	}
+	if got := sc.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("span ID: got %s", got)
+	}
+}
@@ -0,0 +1,41 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/go-github/v69/github"
+)
+
+type GHUsersService interface {
+	Get(ctx context.Context, user string) (*github.User, *github.Response, error)
+}
+
+func NewIdentifyActor(users GHUsersService, opts ...Option) *IdentifyActor {
+	return &IdentifyActor{users: users, options: newOptions(opts)}
+}
+
+// IdentifyActor tells the login of the user the token belongs to, which is recorded as the actor of the writes.
+type IdentifyActor struct {
+	users GHUsersService
+	options
+}
+
+func (u *IdentifyActor) DoIdentifyActor(ctx context.Context) (string, error) {
+	var user *github.User
+	_, err := u.retryPolicy.do(ctx, slog.Default(), u.metrics, "GetAuthenticatedUser", func() (*github.Response, error) {
+		reqCtx, cancel := u.requestContext(ctx)
+		defer cancel()
+		var (
+			resp    *github.Response
+			callErr error
+		)
+		user, resp, callErr = u.users.Get(reqCtx, "")
+		return resp, callErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("GetAuthenticatedUser: %w", err)
+	}
+	return user.GetLogin(), nil
+}
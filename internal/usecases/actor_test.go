@@ -0,0 +1,52 @@
+package usecases_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIdentifyActor_Do(t *testing.T) {
+	forbidden := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}}
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGHUsersService)
+		name    string
+		want    string
+	}{
+		{
+			name: "ok",
+			doMock: func(m *MockGHUsersService) {
+				m.EXPECT().Get(gomock.Any(), "").Return(&github.User{Login: ref("octocat")}, &github.Response{}, nil).Times(1)
+			},
+			want: "octocat",
+		},
+		{
+			name: "forbidden",
+			doMock: func(m *MockGHUsersService) {
+				m.EXPECT().Get(gomock.Any(), "").Return(nil, &github.Response{Response: forbidden.Response}, forbidden).Times(1)
+			},
+			wantErr: forbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			users := NewMockGHUsersService(ctrl)
+			tc.doMock(users)
+			got, gotErr := usecases.
+				NewIdentifyActor(users, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1})).
+				DoIdentifyActor(t.Context())
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if got != tc.want {
+				t.Errorf("actor: want=%q got=%q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// PublicKeyCache keeps the public keys used to seal secrets between runs.
+// Scopes are repos/OWNER/REPO, orgs/ORG or repos/OWNER/REPO/environments/ENV, following the API paths of the keys.
+type PublicKeyCache interface {
+	// Get returns nil without an error if the key of the scope is not cached or has expired.
+	Get(ctx context.Context, scope string) (*github.PublicKey, error)
+	Put(ctx context.Context, scope string, key *github.PublicKey) error
+	Invalidate(ctx context.Context, scope string) error
+}
+
+// WithPublicKeyCache makes the usecase look up the public keys in the cache before asking GitHub.
+func WithPublicKeyCache(c PublicKeyCache) Option {
+	return func(o *options) { o.keyCache = c }
+}
+
+func repoKeyScope(owner, repo string) string { return "repos/" + owner + "/" + repo }
+
+// isStaleKeyError tells whether GitHub rejected the write because the secret was sealed with a key that is no longer current.
+// GitHub responds 422 for other invalid writes too, so a key fetched just now is blamed only if the response refers to key_id.
+func isStaleKeyError(err error, cached bool) bool {
+	errResp := new(github.ErrorResponse)
+	if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	if cached || strings.Contains(errResp.Message, keyIDField) {
+		return true
+	}
+	for _, e := range errResp.Errors {
+		if e.Field == keyIDField || strings.Contains(e.Message, keyIDField) {
+			return true
+		}
+	}
+	return false
+}
+
+const keyIDField = "key_id"
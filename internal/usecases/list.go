@@ -0,0 +1,50 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func NewListOrganizationRepositories(repos GHRepositoriesService, opts ...Option) *ListOrganizationRepositories {
+	return &ListOrganizationRepositories{repos: repos, options: newOptions(opts)}
+}
+
+// ListOrganizationRepositories lists the repositories of an organization that can hold secrets, that is the ones not archived.
+type ListOrganizationRepositories struct {
+	repos GHRepositoriesService
+	options
+}
+
+func (u *ListOrganizationRepositories) DoListOrganizationRepositories(ctx context.Context, org string) ([]string, error) {
+	logger := slog.Default().With(slog.String("org.name", org))
+	var names []string
+	listOpts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var (
+			page []*github.Repository
+			resp *github.Response
+		)
+		_, err := u.retryPolicy.do(ctx, logger, u.metrics, "ListOrganizationRepositories", func() (*github.Response, error) {
+			reqCtx, cancel := u.requestContext(ctx)
+			defer cancel()
+			var callErr error
+			page, resp, callErr = u.repos.ListByOrg(reqCtx, org, listOpts)
+			return resp, callErr
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ListOrganizationRepositories: %w", err)
+		}
+		for _, repo := range page {
+			if !repo.GetArchived() {
+				names = append(names, repo.GetName())
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return names, nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,61 @@
+package usecases_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListOrganizationRepositories_Do(t *testing.T) {
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGHRepositoriesService)
+		name    string
+		want    []string
+	}{
+		{
+			name: "paginated",
+			doMock: func(m *MockGHRepositoriesService) {
+				first := m.EXPECT().
+					ListByOrg(gomock.Any(), "myorg", &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}).
+					Return([]*github.Repository{{Name: ref("repo1")}, {Name: ref("old"), Archived: ref(true)}}, &github.Response{NextPage: 2}, nil).
+					Times(1)
+				m.EXPECT().
+					ListByOrg(gomock.Any(), "myorg", &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100, Page: 2}}).
+					Return([]*github.Repository{{Name: ref("repo2")}}, &github.Response{}, nil).
+					Times(1).
+					After(first)
+			},
+			want: []string{"repo1", "repo2"},
+		},
+		{
+			name: "not found",
+			doMock: func(m *MockGHRepositoriesService) {
+				m.EXPECT().ListByOrg(gomock.Any(), "myorg", gomock.Any()).Return(nil, &github.Response{Response: notFound.Response}, notFound).Times(1)
+			},
+			wantErr: notFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repos := NewMockGHRepositoriesService(ctrl)
+			tc.doMock(repos)
+			got, gotErr := usecases.
+				NewListOrganizationRepositories(repos, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1})).
+				DoListOrganizationRepositories(t.Context(), "myorg")
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("repositories (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aereal/register-github-secret/internal/usecases (interfaces: GHActionsService)
+// Source: github.com/aereal/register-github-secret/internal/usecases (interfaces: GHActionsService,GHRepositoriesService,GHUsersService,PublicKeyCache)
 
 // Package usecases_test is a generated GoMock package.
 package usecases_test
@@ -114,3 +114,350 @@ func (c *MockGHActionsServiceGetRepoPublicKeyCall) DoAndReturn(f func(context.Co
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// ListRepoSecrets mocks base method.
+func (m *MockGHActionsService) ListRepoSecrets(ctx context.Context, owner, repo string, opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRepoSecrets", ctx, owner, repo, opts)
+	ret0, _ := ret[0].(*github.Secrets)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListRepoSecrets indicates an expected call of ListRepoSecrets.
+func (mr *MockGHActionsServiceMockRecorder) ListRepoSecrets(ctx, owner, repo, opts any) *MockGHActionsServiceListRepoSecretsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRepoSecrets", reflect.TypeOf((*MockGHActionsService)(nil).ListRepoSecrets), ctx, owner, repo, opts)
+	return &MockGHActionsServiceListRepoSecretsCall{Call: call}
+}
+
+// MockGHActionsServiceListRepoSecretsCall wrap *gomock.Call
+type MockGHActionsServiceListRepoSecretsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHActionsServiceListRepoSecretsCall) Return(arg0 *github.Secrets, arg1 *github.Response, arg2 error) *MockGHActionsServiceListRepoSecretsCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHActionsServiceListRepoSecretsCall) Do(f func(context.Context, string, string, *github.ListOptions) (*github.Secrets, *github.Response, error)) *MockGHActionsServiceListRepoSecretsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHActionsServiceListRepoSecretsCall) DoAndReturn(f func(context.Context, string, string, *github.ListOptions) (*github.Secrets, *github.Response, error)) *MockGHActionsServiceListRepoSecretsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockGHRepositoriesService is a mock of GHRepositoriesService interface.
+type MockGHRepositoriesService struct {
+	ctrl     *gomock.Controller
+	recorder *MockGHRepositoriesServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockGHRepositoriesServiceMockRecorder is the mock recorder for MockGHRepositoriesService.
+type MockGHRepositoriesServiceMockRecorder struct {
+	mock *MockGHRepositoriesService
+}
+
+// NewMockGHRepositoriesService creates a new mock instance.
+func NewMockGHRepositoriesService(ctrl *gomock.Controller) *MockGHRepositoriesService {
+	mock := &MockGHRepositoriesService{ctrl: ctrl}
+	mock.recorder = &MockGHRepositoriesServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGHRepositoriesService) EXPECT() *MockGHRepositoriesServiceMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockGHRepositoriesService) Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, owner, repo)
+	ret0, _ := ret[0].(*github.Repository)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockGHRepositoriesServiceMockRecorder) Get(ctx, owner, repo any) *MockGHRepositoriesServiceGetCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockGHRepositoriesService)(nil).Get), ctx, owner, repo)
+	return &MockGHRepositoriesServiceGetCall{Call: call}
+}
+
+// MockGHRepositoriesServiceGetCall wrap *gomock.Call
+type MockGHRepositoriesServiceGetCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHRepositoriesServiceGetCall) Return(arg0 *github.Repository, arg1 *github.Response, arg2 error) *MockGHRepositoriesServiceGetCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHRepositoriesServiceGetCall) Do(f func(context.Context, string, string) (*github.Repository, *github.Response, error)) *MockGHRepositoriesServiceGetCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHRepositoriesServiceGetCall) DoAndReturn(f func(context.Context, string, string) (*github.Repository, *github.Response, error)) *MockGHRepositoriesServiceGetCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// ListByOrg mocks base method.
+func (m *MockGHRepositoriesService) ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByOrg", ctx, org, opts)
+	ret0, _ := ret[0].([]*github.Repository)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListByOrg indicates an expected call of ListByOrg.
+func (mr *MockGHRepositoriesServiceMockRecorder) ListByOrg(ctx, org, opts any) *MockGHRepositoriesServiceListByOrgCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByOrg", reflect.TypeOf((*MockGHRepositoriesService)(nil).ListByOrg), ctx, org, opts)
+	return &MockGHRepositoriesServiceListByOrgCall{Call: call}
+}
+
+// MockGHRepositoriesServiceListByOrgCall wrap *gomock.Call
+type MockGHRepositoriesServiceListByOrgCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHRepositoriesServiceListByOrgCall) Return(arg0 []*github.Repository, arg1 *github.Response, arg2 error) *MockGHRepositoriesServiceListByOrgCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHRepositoriesServiceListByOrgCall) Do(f func(context.Context, string, *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)) *MockGHRepositoriesServiceListByOrgCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHRepositoriesServiceListByOrgCall) DoAndReturn(f func(context.Context, string, *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)) *MockGHRepositoriesServiceListByOrgCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockGHUsersService is a mock of GHUsersService interface.
+type MockGHUsersService struct {
+	ctrl     *gomock.Controller
+	recorder *MockGHUsersServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockGHUsersServiceMockRecorder is the mock recorder for MockGHUsersService.
+type MockGHUsersServiceMockRecorder struct {
+	mock *MockGHUsersService
+}
+
+// NewMockGHUsersService creates a new mock instance.
+func NewMockGHUsersService(ctrl *gomock.Controller) *MockGHUsersService {
+	mock := &MockGHUsersService{ctrl: ctrl}
+	mock.recorder = &MockGHUsersServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGHUsersService) EXPECT() *MockGHUsersServiceMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockGHUsersService) Get(ctx context.Context, user string) (*github.User, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, user)
+	ret0, _ := ret[0].(*github.User)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockGHUsersServiceMockRecorder) Get(ctx, user any) *MockGHUsersServiceGetCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockGHUsersService)(nil).Get), ctx, user)
+	return &MockGHUsersServiceGetCall{Call: call}
+}
+
+// MockGHUsersServiceGetCall wrap *gomock.Call
+type MockGHUsersServiceGetCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockGHUsersServiceGetCall) Return(arg0 *github.User, arg1 *github.Response, arg2 error) *MockGHUsersServiceGetCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockGHUsersServiceGetCall) Do(f func(context.Context, string) (*github.User, *github.Response, error)) *MockGHUsersServiceGetCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockGHUsersServiceGetCall) DoAndReturn(f func(context.Context, string) (*github.User, *github.Response, error)) *MockGHUsersServiceGetCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockPublicKeyCache is a mock of PublicKeyCache interface.
+type MockPublicKeyCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockPublicKeyCacheMockRecorder
+	isgomock struct{}
+}
+
+// MockPublicKeyCacheMockRecorder is the mock recorder for MockPublicKeyCache.
+type MockPublicKeyCacheMockRecorder struct {
+	mock *MockPublicKeyCache
+}
+
+// NewMockPublicKeyCache creates a new mock instance.
+func NewMockPublicKeyCache(ctrl *gomock.Controller) *MockPublicKeyCache {
+	mock := &MockPublicKeyCache{ctrl: ctrl}
+	mock.recorder = &MockPublicKeyCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPublicKeyCache) EXPECT() *MockPublicKeyCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockPublicKeyCache) Get(ctx context.Context, scope string) (*github.PublicKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, scope)
+	ret0, _ := ret[0].(*github.PublicKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockPublicKeyCacheMockRecorder) Get(ctx, scope any) *MockPublicKeyCacheGetCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPublicKeyCache)(nil).Get), ctx, scope)
+	return &MockPublicKeyCacheGetCall{Call: call}
+}
+
+// MockPublicKeyCacheGetCall wrap *gomock.Call
+type MockPublicKeyCacheGetCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockPublicKeyCacheGetCall) Return(arg0 *github.PublicKey, arg1 error) *MockPublicKeyCacheGetCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockPublicKeyCacheGetCall) Do(f func(context.Context, string) (*github.PublicKey, error)) *MockPublicKeyCacheGetCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockPublicKeyCacheGetCall) DoAndReturn(f func(context.Context, string) (*github.PublicKey, error)) *MockPublicKeyCacheGetCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Invalidate mocks base method.
+func (m *MockPublicKeyCache) Invalidate(ctx context.Context, scope string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Invalidate", ctx, scope)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockPublicKeyCacheMockRecorder) Invalidate(ctx, scope any) *MockPublicKeyCacheInvalidateCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockPublicKeyCache)(nil).Invalidate), ctx, scope)
+	return &MockPublicKeyCacheInvalidateCall{Call: call}
+}
+
+// MockPublicKeyCacheInvalidateCall wrap *gomock.Call
+type MockPublicKeyCacheInvalidateCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockPublicKeyCacheInvalidateCall) Return(arg0 error) *MockPublicKeyCacheInvalidateCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockPublicKeyCacheInvalidateCall) Do(f func(context.Context, string) error) *MockPublicKeyCacheInvalidateCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockPublicKeyCacheInvalidateCall) DoAndReturn(f func(context.Context, string) error) *MockPublicKeyCacheInvalidateCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Put mocks base method.
+func (m *MockPublicKeyCache) Put(ctx context.Context, scope string, key *github.PublicKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Put", ctx, scope, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Put indicates an expected call of Put.
+func (mr *MockPublicKeyCacheMockRecorder) Put(ctx, scope, key any) *MockPublicKeyCachePutCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockPublicKeyCache)(nil).Put), ctx, scope, key)
+	return &MockPublicKeyCachePutCall{Call: call}
+}
+
+// MockPublicKeyCachePutCall wrap *gomock.Call
+type MockPublicKeyCachePutCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockPublicKeyCachePutCall) Return(arg0 error) *MockPublicKeyCachePutCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockPublicKeyCachePutCall) Do(f func(context.Context, string, *github.PublicKey) error) *MockPublicKeyCachePutCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockPublicKeyCachePutCall) DoAndReturn(f func(context.Context, string, *github.PublicKey) error) *MockPublicKeyCachePutCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
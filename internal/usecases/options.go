@@ -0,0 +1,58 @@
+package usecases
+
+import (
+	"context"
+	"time"
+)
+
+type Option func(*options)
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) { o.retryPolicy = p }
+}
+
+// WithRequestTimeout bounds each GitHub API request; zero means no limit.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(o *options) { o.requestTimeout = d }
+}
+
+// Metrics is told about the GitHub API calls made by the usecases; it must be safe for concurrent use.
+type Metrics interface {
+	// ObserveAPICall is called for each attempt; status is zero when no response was received.
+	ObserveAPICall(operation string, status int, elapsed time.Duration)
+	CountRetry(operation string)
+	ObserveRateLimitWait(operation string, wait time.Duration)
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveAPICall(string, int, time.Duration)  {}
+func (nopMetrics) CountRetry(string)                          {}
+func (nopMetrics) ObserveRateLimitWait(string, time.Duration) {}
+
+type options struct {
+	// keyCache is nil unless the public keys are cached.
+	keyCache       PublicKeyCache
+	metrics        Metrics
+	retryPolicy    RetryPolicy
+	requestTimeout time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{retryPolicy: DefaultRetryPolicy, metrics: nopMetrics{}}
+	for _, f := range opts {
+		f(&o)
+	}
+	return o
+}
+
+func (o options) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.requestTimeout)
+}
@@ -0,0 +1,148 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v69/github"
+)
+
+type GHRepositoriesService interface {
+	Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)
+}
+
+// InsufficientPermissionError tells that the token cannot write secrets to the repository.
+type InsufficientPermissionError struct {
+	Err    error
+	Owner  string
+	Repo   string
+	Reason string
+}
+
+func (e *InsufficientPermissionError) Error() string {
+	return fmt.Sprintf("insufficient permission on %s/%s: %s", e.Owner, e.Repo, e.Reason)
+}
+
+func (e *InsufficientPermissionError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("owner", e.Owner), slog.String("repo", e.Repo), slog.String("reason", e.Reason))
+}
+
+func (e *InsufficientPermissionError) Unwrap() error { return e.Err }
+
+func (e *InsufficientPermissionError) Is(err error) bool {
+	thatErr := new(InsufficientPermissionError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Owner == thatErr.Owner && e.Repo == thatErr.Repo && e.Reason == thatErr.Reason
+}
+
+func NewCheckRepositoryAccess(repos GHRepositoriesService, actions GHActionsService, opts ...Option) *CheckRepositoryAccess {
+	return &CheckRepositoryAccess{repos: repos, actions: actions, options: newOptions(opts)}
+}
+
+// CheckRepositoryAccess tells beforehand whether the secrets of a repository can be written, without writing anything.
+// Only the classic personal access tokens and OAuth tokens report their permissions; for the other tokens it tells only
+// whether the secrets can be read, and RepositoryAccess says so.
+type CheckRepositoryAccess struct {
+	repos   GHRepositoriesService
+	actions GHActionsService
+	options
+}
+
+// RepositoryAccess is the access to the secrets of a repository found by CheckRepositoryAccess.
+type RepositoryAccess struct {
+	// WriteVerified is false if only reading the secrets was checked,
+	// as fine-grained tokens and GitHub App installations do not report whether they can write them.
+	WriteVerified bool
+}
+
+func (u *CheckRepositoryAccess) DoCheckRepositoryAccess(ctx context.Context, repoOwner string, repoName string) (*RepositoryAccess, error) {
+	logger := slog.Default().With(
+		slog.String("repo.owner", repoOwner),
+		slog.String("repo.name", repoName),
+	)
+	var (
+		repo *github.Repository
+		resp *github.Response
+	)
+	_, err := u.retryPolicy.do(ctx, logger, u.metrics, "GetRepository", func() (*github.Response, error) {
+		reqCtx, cancel := u.requestContext(ctx)
+		defer cancel()
+		var callErr error
+		repo, resp, callErr = u.repos.Get(reqCtx, repoOwner, repoName)
+		return resp, callErr
+	})
+	if isDenied(err) {
+		return nil, &InsufficientPermissionError{Owner: repoOwner, Repo: repoName, Reason: "repository is not found or not accessible", Err: err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetRepository: %w", err)
+	}
+	if repo.GetArchived() {
+		return nil, &InsufficientPermissionError{Owner: repoOwner, Repo: repoName, Reason: "repository is archived"}
+	}
+	// Only classic personal access tokens and OAuth tokens report their scopes and the permissions of the user.
+	// Fine-grained tokens and GitHub App installations are checked by the public key probe below, which needs only the read permission.
+	scopes, writeVerified := oauthScopes(resp)
+	if writeVerified {
+		if !slices.Contains(scopes, "repo") {
+			return nil, &InsufficientPermissionError{Owner: repoOwner, Repo: repoName, Reason: "token lacks the repo scope"}
+		}
+		if perms := repo.GetPermissions(); perms != nil && !perms["admin"] {
+			return nil, &InsufficientPermissionError{Owner: repoOwner, Repo: repoName, Reason: "admin permission on the repository is required"}
+		}
+	}
+	_, err = u.retryPolicy.do(ctx, logger, u.metrics, "GetRepoPublicKey", func() (*github.Response, error) {
+		reqCtx, cancel := u.requestContext(ctx)
+		defer cancel()
+		_, keyResp, callErr := u.actions.GetRepoPublicKey(reqCtx, repoOwner, repoName)
+		return keyResp, callErr
+	})
+	if isDenied(err) {
+		return nil, &InsufficientPermissionError{Owner: repoOwner, Repo: repoName, Reason: "token cannot access Actions secrets", Err: err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetRepoPublicKey: %w", err)
+	}
+	return &RepositoryAccess{WriteVerified: writeVerified}, nil
+}
+
+// oauthScopes returns the scopes granted to the token, and false if the token does not report them.
+func oauthScopes(resp *github.Response) ([]string, bool) {
+	if resp == nil || resp.Response == nil {
+		return nil, false
+	}
+	values, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	if !ok {
+		return nil, false
+	}
+	var scopes []string
+	for _, v := range values {
+		for s := range strings.SplitSeq(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes, true
+}
+
+func isDenied(err error) bool {
+	errResp := new(github.ErrorResponse)
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+	switch errResp.Response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	default:
+		return false
+	}
+}
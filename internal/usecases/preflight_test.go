@@ -0,0 +1,107 @@
+package usecases_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCheckRepositoryAccess_Do(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	forbidden := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}}
+	testCases := []struct {
+		wantErr    error
+		wantAccess *usecases.RepositoryAccess
+		doMock     func(repos *MockGHRepositoriesService, actions *MockGHActionsService)
+		name       string
+	}{
+		{
+			name: "classic token with enough permission",
+			doMock: func(repos *MockGHRepositoriesService, actions *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{Permissions: map[string]bool{"admin": true}}, responseWithScopes("repo, workflow"), nil)
+				_ = succeedsGetRepoPublicKey(actions, pubKey)
+			},
+			wantAccess: &usecases.RepositoryAccess{WriteVerified: true},
+		},
+		{
+			name: "fine-grained token",
+			doMock: func(repos *MockGHRepositoriesService, actions *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{Permissions: map[string]bool{"admin": false}}, &github.Response{Response: &http.Response{Header: http.Header{}}}, nil)
+				_ = succeedsGetRepoPublicKey(actions, pubKey)
+			},
+			wantAccess: &usecases.RepositoryAccess{WriteVerified: false},
+		},
+		{
+			name: "archived repository",
+			doMock: func(repos *MockGHRepositoriesService, _ *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{Archived: github.Ptr(true), Permissions: map[string]bool{"admin": true}}, responseWithScopes("repo"), nil)
+			},
+			wantErr: &usecases.InsufficientPermissionError{Owner: "aereal", Repo: "myrepo", Reason: "repository is archived"},
+		},
+		{
+			name: "repository not accessible",
+			doMock: func(repos *MockGHRepositoriesService, _ *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").Return(nil, &github.Response{Response: notFound.Response}, notFound)
+			},
+			wantErr: &usecases.InsufficientPermissionError{Owner: "aereal", Repo: "myrepo", Reason: "repository is not found or not accessible", Err: notFound},
+		},
+		{
+			name: "classic token without repo scope",
+			doMock: func(repos *MockGHRepositoriesService, _ *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{Permissions: map[string]bool{"admin": true}}, responseWithScopes("public_repo"), nil)
+			},
+			wantErr: assertions.LiteralError("insufficient permission on aereal/myrepo: token lacks the repo scope"),
+		},
+		{
+			name: "classic token of non-admin user",
+			doMock: func(repos *MockGHRepositoriesService, _ *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{Permissions: map[string]bool{"admin": false, "push": true}}, responseWithScopes("repo"), nil)
+			},
+			wantErr: assertions.LiteralError("insufficient permission on aereal/myrepo: admin permission on the repository is required"),
+		},
+		{
+			name: "secrets not accessible",
+			doMock: func(repos *MockGHRepositoriesService, actions *MockGHActionsService) {
+				repos.EXPECT().Get(gomock.Any(), "aereal", "myrepo").
+					Return(&github.Repository{}, &github.Response{Response: &http.Response{Header: http.Header{}}}, nil)
+				actions.EXPECT().GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").Return(nil, &github.Response{Response: forbidden.Response}, forbidden)
+			},
+			wantErr: assertions.LiteralError("insufficient permission on aereal/myrepo: token cannot access Actions secrets"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repos := NewMockGHRepositoriesService(ctrl)
+			actions := NewMockGHActionsService(ctrl)
+			tc.doMock(repos, actions)
+			got, gotErr := usecases.
+				NewCheckRepositoryAccess(repos, actions, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1})).
+				DoCheckRepositoryAccess(t.Context(), "aereal", "myrepo")
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantAccess, got); diff != "" {
+				t.Errorf("access (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func responseWithScopes(scopes string) *github.Response {
+	return &github.Response{Response: &http.Response{Header: http.Header{"X-Oauth-Scopes": []string{scopes}}}}
+}
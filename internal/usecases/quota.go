@@ -0,0 +1,126 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/go-github/v69/github"
+)
+
+const (
+	// MaxSecretSize is the largest secret value GitHub stores, in bytes.
+	MaxSecretSize = 48 * 1024
+	// MaxRepositorySecrets is the number of secrets a repository can hold.
+	MaxRepositorySecrets = 100
+)
+
+// SecretTooLargeError tells that GitHub would refuse the secret value for its size.
+type SecretTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *SecretTooLargeError) Error() string {
+	return fmt.Sprintf("secret value is %d bytes, larger than the limit of %d bytes", e.Size, e.Limit)
+}
+
+func (e *SecretTooLargeError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("size", e.Size), slog.Int("limit", e.Limit))
+}
+
+func (e *SecretTooLargeError) Is(err error) bool {
+	thatErr := new(SecretTooLargeError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return e.Size == thatErr.Size && e.Limit == thatErr.Limit
+}
+
+// CheckSecretSize tells whether the plain secret value fits in the limit of GitHub.
+func CheckSecretSize(plainMsg []byte) error {
+	if len(plainMsg) > MaxSecretSize {
+		return &SecretTooLargeError{Size: len(plainMsg), Limit: MaxSecretSize}
+	}
+	return nil
+}
+
+// SecretQuotaExceededError tells that writing the new secrets would make the repository hold more secrets than GitHub allows.
+type SecretQuotaExceededError struct {
+	Owner    string
+	Repo     string
+	Existing int
+	Adding   int
+	Limit    int
+}
+
+func (e *SecretQuotaExceededError) Error() string {
+	return fmt.Sprintf("%s/%s has %d secrets and %d more would exceed the limit of %d", e.Owner, e.Repo, e.Existing, e.Adding, e.Limit)
+}
+
+func (e *SecretQuotaExceededError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("owner", e.Owner), slog.String("repo", e.Repo), slog.Int("existing", e.Existing), slog.Int("adding", e.Adding), slog.Int("limit", e.Limit))
+}
+
+func (e *SecretQuotaExceededError) Is(err error) bool {
+	thatErr := new(SecretQuotaExceededError)
+	if !errors.As(err, &thatErr) {
+		return false
+	}
+	return *e == *thatErr
+}
+
+func NewCheckSecretQuota(client GHActionsService, opts ...Option) *CheckSecretQuota {
+	return &CheckSecretQuota{client: client, options: newOptions(opts)}
+}
+
+// CheckSecretQuota tells beforehand whether the repository has room for the secrets, counting the existing ones.
+type CheckSecretQuota struct {
+	client GHActionsService
+	options
+}
+
+// DoCheckSecretQuota counts the names not yet in the repository; overwriting an existing secret takes no room.
+func (u *CheckSecretQuota) DoCheckSecretQuota(ctx context.Context, repoOwner string, repoName string, secretNames []string) error {
+	logger := slog.Default().With(
+		slog.String("repo.owner", repoOwner),
+		slog.String("repo.name", repoName),
+	)
+	existing := map[string]bool{}
+	listOpts := &github.ListOptions{PerPage: 100}
+	for {
+		var (
+			secrets *github.Secrets
+			resp    *github.Response
+		)
+		_, err := u.retryPolicy.do(ctx, logger, u.metrics, "ListRepoSecrets", func() (*github.Response, error) {
+			reqCtx, cancel := u.requestContext(ctx)
+			defer cancel()
+			var callErr error
+			secrets, resp, callErr = u.client.ListRepoSecrets(reqCtx, repoOwner, repoName, listOpts)
+			return resp, callErr
+		})
+		if err != nil {
+			return fmt.Errorf("ListRepoSecrets: %w", err)
+		}
+		for _, s := range secrets.Secrets {
+			existing[strings.ToUpper(s.Name)] = true
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+	adding := map[string]bool{}
+	for _, name := range secretNames {
+		if folded := strings.ToUpper(name); !existing[folded] {
+			adding[folded] = true
+		}
+	}
+	if len(existing)+len(adding) > MaxRepositorySecrets {
+		return &SecretQuotaExceededError{Owner: repoOwner, Repo: repoName, Existing: len(existing), Adding: len(adding), Limit: MaxRepositorySecrets}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package usecases_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func existingSecrets(from, to int) *github.Secrets {
+	secrets := &github.Secrets{TotalCount: usecases.MaxRepositorySecrets}
+	for i := from; i < to; i++ {
+		secrets.Secrets = append(secrets.Secrets, &github.Secret{Name: fmt.Sprintf("SECRET_%d", i)})
+	}
+	return secrets
+}
+
+func TestCheckSecretQuota_Do(t *testing.T) {
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGHActionsService)
+		name    string
+		names   []string
+	}{
+		{
+			name:  "room left",
+			names: []string{"NEW_SECRET"},
+			doMock: func(m *MockGHActionsService) {
+				m.EXPECT().ListRepoSecrets(gomock.Any(), "aereal", "myrepo", &github.ListOptions{PerPage: 100}).Return(existingSecrets(0, 99), &github.Response{}, nil).Times(1)
+			},
+		},
+		{
+			name:  "full",
+			names: []string{"NEW_SECRET", "secret_0"},
+			doMock: func(m *MockGHActionsService) {
+				first := m.EXPECT().ListRepoSecrets(gomock.Any(), "aereal", "myrepo", &github.ListOptions{PerPage: 100}).Return(existingSecrets(0, 60), &github.Response{NextPage: 2}, nil).Times(1)
+				m.EXPECT().ListRepoSecrets(gomock.Any(), "aereal", "myrepo", &github.ListOptions{PerPage: 100, Page: 2}).Return(existingSecrets(60, 100), &github.Response{}, nil).Times(1).After(first)
+			},
+			wantErr: &usecases.SecretQuotaExceededError{Owner: "aereal", Repo: "myrepo", Existing: 100, Adding: 1, Limit: usecases.MaxRepositorySecrets},
+		},
+		{
+			name:  "overwrite only",
+			names: []string{"secret_0", "SECRET_1"},
+			doMock: func(m *MockGHActionsService) {
+				m.EXPECT().ListRepoSecrets(gomock.Any(), "aereal", "myrepo", gomock.Any()).Return(existingSecrets(0, 100), &github.Response{}, nil).Times(1)
+			},
+		},
+		{
+			name:  "failed to list",
+			names: []string{"NEW_SECRET"},
+			doMock: func(m *MockGHActionsService) {
+				m.EXPECT().ListRepoSecrets(gomock.Any(), "aereal", "myrepo", gomock.Any()).Return(nil, nil, errFailed).Times(1)
+			},
+			wantErr: errFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := NewMockGHActionsService(ctrl)
+			tc.doMock(client)
+			gotErr := usecases.
+				NewCheckSecretQuota(client, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1})).
+				DoCheckSecretQuota(t.Context(), "aereal", "myrepo", tc.names)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckSecretSize(t *testing.T) {
+	if err := usecases.CheckSecretSize([]byte(strings.Repeat("a", usecases.MaxSecretSize))); err != nil {
+		t.Errorf("the value at the limit: %v", err)
+	}
+	want := &usecases.SecretTooLargeError{Size: usecases.MaxSecretSize + 1, Limit: usecases.MaxSecretSize}
+	if diff := assertions.DiffErrorsConservatively(want, usecases.CheckSecretSize([]byte(strings.Repeat("a", usecases.MaxSecretSize+1)))); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRegisterRepositorySecret_Do_tooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockGHActionsService(ctrl)
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the value is refused when it is sealed, before anything is written
+	succeedsGetRepoPublicKey(client, pubKey).Times(1)
+	_, gotErr := usecases.NewRegisterRepositorySecret(client).
+		DoRegisterRepositorySecret(t.Context(), "aereal", "myrepo", "MY_SECRET", strings.Repeat("a", usecases.MaxSecretSize+1))
+	want := &usecases.SecretTooLargeError{Size: usecases.MaxSecretSize + 1, Limit: usecases.MaxSecretSize}
+	if diff := assertions.DiffErrorsConservatively(want, gotErr); diff != "" {
+		t.Errorf("error (-want, +got):\n%s", diff)
+	}
+}
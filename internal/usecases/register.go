@@ -1,3 +1,5 @@
+//go:generate go tool mockgen -destination ./mock_test.go -package usecases_test -typed -write_command_comment=false github.com/aereal/register-github-secret/internal/usecases GHActionsService,GHRepositoriesService,GHUsersService,PublicKeyCache
+
 package usecases
 
 import (
@@ -8,7 +10,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 
+	"github.com/aereal/register-github-secret/internal/log"
 	"github.com/google/go-github/v69/github"
 	"golang.org/x/crypto/nacl/box"
 )
@@ -16,46 +20,188 @@ import (
 type GHActionsService interface {
 	GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error)
 	CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error)
+	ListRepoSecrets(ctx context.Context, owner, repo string, opts *github.ListOptions) (*github.Secrets, *github.Response, error)
+}
+
+func NewRegisterRepositorySecret(client GHActionsService, opts ...Option) *RegisterRepositorySecret {
+	return &RegisterRepositorySecret{client: client, options: newOptions(opts)}
 }
 
-func NewRegisterRepositorySecret(client GHActionsService) *RegisterRepositorySecret {
-	return &RegisterRepositorySecret{client: client}
+type SecretAction string
+
+const (
+	SecretActionCreated SecretAction = "created"
+	SecretActionUpdated SecretAction = "updated"
+)
+
+// RegisterResult describes what a successful registration did.
+type RegisterResult struct {
+	Action SecretAction
+	KeyID  string
+	// Attempts is the total number of GitHub API requests made, including retries.
+	Attempts int
 }
 
 type RegisterRepositorySecret struct {
 	client GHActionsService
+	options
 }
 
-func (u *RegisterRepositorySecret) DoRegisterRepositorySecret(ctx context.Context, repoOwner string, repoName string, secretName string, plainMsg string) error {
-	pubKey, _, err := u.client.GetRepoPublicKey(ctx, repoOwner, repoName)
+func (u *RegisterRepositorySecret) DoRegisterRepositorySecret(ctx context.Context, repoOwner string, repoName string, secretName string, plainMsg string) (*RegisterResult, error) {
+	logger := slog.Default().With(
+		slog.String("repo.owner", repoOwner),
+		slog.String("repo.name", repoName),
+		slog.String("secret.name", secretName),
+	)
+	scope := repoKeyScope(repoOwner, repoName)
+	pubKey, getKeyAttempts, cached, err := u.publicKey(ctx, logger, scope, repoOwner, repoName)
 	if err != nil {
-		return fmt.Errorf("GetRepoPublicKey: %w", err)
+		return nil, err
 	}
-	serverPubKey, err := getRawPublicKey(pubKey)
-	if err != nil {
-		return err
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("not written: %w", ctxErr)
+	}
+	logger.InfoContext(ctx, "set repository secret")
+	createResp, keyID, createAttempts, err := u.write(ctx, logger, repoOwner, repoName, secretName, plainMsg, pubKey)
+	if isStaleKeyError(err, cached) {
+		// the key may have been rotated since it was fetched or cached; seal again with the current key and retry once
+		if cached {
+			u.invalidateKey(ctx, logger, scope)
+		}
+		freshKey, fetchAttempts, fetchErr := u.fetchPublicKey(ctx, logger, scope, repoOwner, repoName)
+		getKeyAttempts += fetchAttempts
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		if freshKey.GetKeyID() != pubKey.GetKeyID() {
+			logger.InfoContext(ctx, "public key was rotated; retry with the new key",
+				slog.String("key.previous_id", pubKey.GetKeyID()),
+				slog.String("key.id", freshKey.GetKeyID()),
+			)
+			var retryAttempts int
+			createResp, keyID, retryAttempts, err = u.write(ctx, logger, repoOwner, repoName, secretName, plainMsg, freshKey)
+			createAttempts += retryAttempts
+		}
 	}
-	encrypted, err := encryptAndEncode([]byte(plainMsg), serverPubKey)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("CreateOrUpdateRepoSecret (%d attempts): %w", createAttempts, err)
 	}
-	secret := &github.EncryptedSecret{
-		Name:           secretName,
-		KeyID:          pubKey.GetKeyID(),
-		EncryptedValue: encrypted,
+	result := &RegisterResult{
+		Action:   actionFromResponse(createResp),
+		KeyID:    keyID,
+		Attempts: getKeyAttempts + createAttempts,
 	}
-	slog.InfoContext(ctx, "set repository secret",
-		slog.String("repo.owner", repoOwner),
-		slog.String("repo.name", repoName),
-		slog.String("secret.name", secretName),
+	logger.InfoContext(ctx, "repository secret set",
+		slog.String("secret.action", string(result.Action)),
+		slog.Int("api.get_public_key.attempts", getKeyAttempts),
+		slog.Int("api.create_or_update_secret.attempts", createAttempts),
 	)
-	if _, err := u.client.CreateOrUpdateRepoSecret(ctx, repoOwner, repoName, secret); err != nil {
-		return fmt.Errorf("CreateOrUpdateRepoSecret: %w", err)
+	return result, nil
+}
+
+// publicKey returns the public key of the repository from the cache if any, otherwise from GitHub.
+// attempts counts the requests to GitHub and cached tells that the key came from the cache.
+func (u *RegisterRepositorySecret) publicKey(ctx context.Context, logger *slog.Logger, scope, repoOwner, repoName string) (key *github.PublicKey, attempts int, cached bool, err error) {
+	if u.keyCache != nil {
+		key, err = u.keyCache.Get(ctx, scope)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to read the public key cache", log.AttrError(err))
+		}
+		if key != nil {
+			return key, 0, true, nil
+		}
+	}
+	key, attempts, err = u.fetchPublicKey(ctx, logger, scope, repoOwner, repoName)
+	return key, attempts, false, err
+}
+
+// fetchPublicKey asks GitHub for the current public key of the repository and caches it.
+func (u *RegisterRepositorySecret) fetchPublicKey(ctx context.Context, logger *slog.Logger, scope, repoOwner, repoName string) (*github.PublicKey, int, error) {
+	key, attempts, err := u.getRepoPublicKey(ctx, logger, u.client, repoOwner, repoName)
+	if err != nil {
+		return nil, attempts, err
+	}
+	if u.keyCache != nil {
+		if putErr := u.keyCache.Put(ctx, scope, key); putErr != nil {
+			logger.WarnContext(ctx, "failed to write the public key cache", log.AttrError(putErr))
+		}
 	}
-	return nil
+	return key, attempts, nil
+}
+
+func (u *RegisterRepositorySecret) invalidateKey(ctx context.Context, logger *slog.Logger, scope string) {
+	if err := u.keyCache.Invalidate(ctx, scope); err != nil {
+		logger.WarnContext(ctx, "failed to invalidate the public key cache", log.AttrError(err))
+	}
+}
+
+// write seals the value with pubKey and writes it, returning the ID of the key used.
+func (u *RegisterRepositorySecret) write(ctx context.Context, logger *slog.Logger, repoOwner, repoName, secretName, plainMsg string, pubKey *github.PublicKey) (*github.Response, string, int, error) {
+	sealed, err := SealSecret(pubKey, secretName, plainMsg)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	createResp, attempts, err := u.putRepoSecret(ctx, logger, u.client, repoOwner, repoName, sealed.encryptedSecret())
+	return createResp, sealed.KeyID, attempts, err
+}
+
+// getRepoPublicKey fetches the public key of the repository, retrying transient failures.
+func (o options) getRepoPublicKey(ctx context.Context, logger *slog.Logger, client GHActionsService, repoOwner, repoName string) (*github.PublicKey, int, error) {
+	var key *github.PublicKey
+	attempts, err := o.retryPolicy.do(ctx, logger, o.metrics, "GetRepoPublicKey", func() (*github.Response, error) {
+		reqCtx, cancel := o.requestContext(ctx)
+		defer cancel()
+		var (
+			resp    *github.Response
+			callErr error
+		)
+		key, resp, callErr = client.GetRepoPublicKey(reqCtx, repoOwner, repoName)
+		return resp, callErr
+	})
+	if err != nil {
+		return nil, attempts, fmt.Errorf("GetRepoPublicKey (%d attempts): %w", attempts, err)
+	}
+	return key, attempts, nil
+}
+
+// putRepoSecret writes the sealed secret, retrying transient failures.
+func (o options) putRepoSecret(ctx context.Context, logger *slog.Logger, client GHActionsService, repoOwner, repoName string, secret *github.EncryptedSecret) (*github.Response, int, error) {
+	var resp *github.Response
+	attempts, err := o.retryPolicy.do(ctx, logger, o.metrics, "CreateOrUpdateRepoSecret", func() (*github.Response, error) {
+		// a write that has been started is allowed to finish even if the run is cancelled meanwhile
+		writeCtx, cancelWrite := detachCancel(ctx)
+		defer cancelWrite()
+		reqCtx, cancel := o.requestContext(writeCtx)
+		defer cancel()
+		var callErr error
+		resp, callErr = client.CreateOrUpdateRepoSecret(reqCtx, repoOwner, repoName, secret)
+		return resp, callErr
+	})
+	return resp, attempts, err
+}
+
+// detachCancel returns the context that is not cancelled along with ctx but still ends at the deadline of ctx,
+// so that a write that hangs does not outlive the run.
+func detachCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return detached, func() {}
+}
+
+// actionFromResponse tells whether the secret was newly created; GitHub responds 201 for new secrets and 204 for updates.
+func actionFromResponse(resp *github.Response) SecretAction {
+	if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusCreated {
+		return SecretActionCreated
+	}
+	return SecretActionUpdated
 }
 
 func encryptAndEncode(msg []byte, pubKey *[32]byte) (string, error) {
+	if err := CheckSecretSize(msg); err != nil {
+		return "", err
+	}
 	var out []byte
 	got, err := box.SealAnonymous(out, msg, pubKey, rand.Reader)
 	if err != nil {
@@ -1,15 +1,18 @@
 package usecases_test
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"testing"
 
 	"github.com/aereal/register-github-secret/internal/assertions"
 	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v69/github"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/crypto/nacl/box"
@@ -29,6 +32,7 @@ func TestRegisterRepositorySecret_Do(t *testing.T) {
 	testCases := []struct {
 		wantErr error
 		doMock  func(m *MockGHActionsService)
+		want    *usecases.RegisterResult
 		input   input
 		name    string
 	}{
@@ -45,6 +49,24 @@ func TestRegisterRepositorySecret_Do(t *testing.T) {
 					Times(1).
 					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
 			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 2},
+		},
+		{
+			name: "ok; newly created",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xdeadbeaf"}).
+					Return(&github.Response{Response: &http.Response{StatusCode: http.StatusCreated}}, nil).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionCreated, KeyID: "0xdeadbeaf", Attempts: 2},
 		},
 		{
 			name: "failed to GetRepoPublicKey",
@@ -74,6 +96,127 @@ func TestRegisterRepositorySecret_Do(t *testing.T) {
 			},
 			wantErr: errCreateOrUpdateRepoSecret,
 		},
+		{
+			name: "retry transient GetRepoPublicKey failure",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				succeedsCreateOrUpdateRepoSecret(m).
+					Times(1).
+					After(
+						succeedsGetRepoPublicKey(m, pubKey).
+							Times(1).
+							After(failsGetRepoPublicKeyWithStatus(m, http.StatusBadGateway).Times(2)),
+					)
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 4},
+		},
+		{
+			name: "give up after max attempts",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				_ = failsGetRepoPublicKeyWithStatus(m, http.StatusServiceUnavailable).Times(3)
+			},
+			wantErr: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+		},
+		{
+			name: "do not retry non-transient failure",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				_ = failsGetRepoPublicKeyWithStatus(m, http.StatusNotFound).Times(1)
+			},
+			wantErr: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}},
+		},
+		{
+			name: "retry transient CreateOrUpdateRepoSecret failure",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				succeedsCreateOrUpdateRepoSecret(m).
+					Times(1).
+					After(
+						failsCreateOrUpdateRepoSecretWithStatus(m, http.StatusInternalServerError).
+							Times(1).
+							After(succeedsGetRepoPublicKey(m, pubKey).Times(1)),
+					)
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 3},
+		},
+		{
+			name: "public key rotated before the write",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				rotatedKey := &github.PublicKey{KeyID: ref("0xrotated"), Key: pubKey.Key}
+				unprocessable := &http.Response{StatusCode: http.StatusUnprocessableEntity}
+				rejected := m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xdeadbeaf"}).
+					Return(&github.Response{Response: unprocessable}, &github.ErrorResponse{Response: unprocessable, Errors: []github.Error{{Resource: "Secret", Field: "key_id", Code: "invalid"}}}).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
+				refetched := m.EXPECT().GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").Return(rotatedKey, &github.Response{}, nil).Times(1).After(rejected)
+				m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xrotated"}).
+					Return(&github.Response{}, nil).
+					Times(1).
+					After(refetched)
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xrotated", Attempts: 4},
+		},
+		{
+			name: "rejected for other than the public key",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				_ = failsCreateOrUpdateRepoSecretWithStatus(m, http.StatusUnprocessableEntity).Times(1)
+				_ = succeedsGetRepoPublicKey(m, pubKey).Times(1)
+			},
+			wantErr: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnprocessableEntity}},
+		},
+		{
+			name: "public key rejected although it is current",
+			input: input{
+				repoOwner:  "aereal",
+				repoName:   "myrepo",
+				secretName: "MY_SECRET",
+				plainMsg:   "blah blah",
+			},
+			doMock: func(m *MockGHActionsService) {
+				unprocessable := &http.Response{StatusCode: http.StatusUnprocessableEntity}
+				m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xdeadbeaf"}).
+					Return(&github.Response{Response: unprocessable}, &github.ErrorResponse{Response: unprocessable, Message: "Invalid key_id"}).
+					Times(1)
+				_ = succeedsGetRepoPublicKey(m, pubKey).Times(2)
+			},
+			wantErr: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnprocessableEntity}, Message: "Invalid key_id"},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -83,12 +226,15 @@ func TestRegisterRepositorySecret_Do(t *testing.T) {
 				doMock(mockClient)
 			}
 			ctx := t.Context()
-			gotErr := usecases.
-				NewRegisterRepositorySecret(mockClient).
+			got, gotErr := usecases.
+				NewRegisterRepositorySecret(mockClient, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 3})).
 				DoRegisterRepositorySecret(ctx, testCase.input.repoOwner, testCase.input.repoName, testCase.input.secretName, testCase.input.plainMsg)
 			if diff := assertions.DiffErrorsConservatively(testCase.wantErr, gotErr); diff != "" {
 				t.Errorf("error (-want, +got):\n%s", diff)
 			}
+			if diff := cmp.Diff(testCase.want, got); diff != "" {
+				t.Errorf("result (-want, +got):\n%s", diff)
+			}
 		})
 	}
 }
@@ -107,6 +253,13 @@ func failsGetRepoPublicKey(m *MockGHActionsService) *MockGHActionsServiceGetRepo
 		Return(nil, &github.Response{}, errGetRepoPublicKey)
 }
 
+func failsGetRepoPublicKeyWithStatus(m *MockGHActionsService, statusCode int) *MockGHActionsServiceGetRepoPublicKeyCall {
+	resp := &http.Response{StatusCode: statusCode}
+	return m.EXPECT().
+		GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").
+		Return(nil, &github.Response{Response: resp}, &github.ErrorResponse{Response: resp})
+}
+
 func succeedsCreateOrUpdateRepoSecret(m *MockGHActionsService) *MockGHActionsServiceCreateOrUpdateRepoSecretCall {
 	return m.EXPECT().
 		CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xdeadbeaf"}).
@@ -121,6 +274,13 @@ func failsCreateOrUpdateRepoSecret(m *MockGHActionsService) *MockGHActionsServic
 		Return(nil, errCreateOrUpdateRepoSecret)
 }
 
+func failsCreateOrUpdateRepoSecretWithStatus(m *MockGHActionsService, statusCode int) *MockGHActionsServiceCreateOrUpdateRepoSecretCall {
+	resp := &http.Response{StatusCode: statusCode}
+	return m.EXPECT().
+		CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xdeadbeaf"}).
+		Return(&github.Response{Response: resp}, &github.ErrorResponse{Response: resp})
+}
+
 var (
 	getPublicKey = sync.OnceValues(func() (*github.PublicKey, error) {
 		pubKey, _, err := box.GenerateKey(rand.Reader)
@@ -155,3 +315,107 @@ func (m *encryptedSecretMatcher) Matches(x any) bool {
 func (m *encryptedSecretMatcher) String() string {
 	return fmt.Sprintf("&github.EncryptedSecret{Name=%q; KeyID=%q}", m.name, m.keyID)
 }
+
+func TestRegisterRepositorySecret_Do_cancelled(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := gomock.NewController(t)
+	mockClient := NewMockGHActionsService(ctrl)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	mockClient.EXPECT().
+		GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").
+		DoAndReturn(func(context.Context, string, string) (*github.PublicKey, *github.Response, error) {
+			cancel()
+			return pubKey, &github.Response{}, nil
+		}).
+		Times(1)
+	_, gotErr := usecases.
+		NewRegisterRepositorySecret(mockClient).
+		DoRegisterRepositorySecret(ctx, "aereal", "myrepo", "MY_SECRET", "blah blah")
+	if !errors.Is(gotErr, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", gotErr)
+	}
+}
+
+func TestRegisterRepositorySecret_Do_keyCache(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleKey := &github.PublicKey{KeyID: ref("0xstale"), Key: pubKey.Key}
+	unprocessable := &http.Response{StatusCode: http.StatusUnprocessableEntity}
+	testCases := []struct {
+		doMock func(m *MockGHActionsService, c *MockPublicKeyCache)
+		want   *usecases.RegisterResult
+		name   string
+	}{
+		{
+			name: "cache hit",
+			doMock: func(m *MockGHActionsService, c *MockPublicKeyCache) {
+				c.EXPECT().Get(gomock.Any(), "repos/aereal/myrepo").Return(pubKey, nil).Times(1)
+				succeedsCreateOrUpdateRepoSecret(m).Times(1)
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 1},
+		},
+		{
+			name: "cache miss",
+			doMock: func(m *MockGHActionsService, c *MockPublicKeyCache) {
+				c.EXPECT().Get(gomock.Any(), "repos/aereal/myrepo").Return(nil, nil).Times(1)
+				c.EXPECT().Put(gomock.Any(), "repos/aereal/myrepo", pubKey).Return(nil).Times(1)
+				succeedsCreateOrUpdateRepoSecret(m).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 2},
+		},
+		{
+			name: "cache is broken",
+			doMock: func(m *MockGHActionsService, c *MockPublicKeyCache) {
+				c.EXPECT().Get(gomock.Any(), "repos/aereal/myrepo").Return(nil, errFailed).Times(1)
+				c.EXPECT().Put(gomock.Any(), "repos/aereal/myrepo", pubKey).Return(errFailed).Times(1)
+				succeedsCreateOrUpdateRepoSecret(m).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 2},
+		},
+		{
+			name: "cached key is stale",
+			doMock: func(m *MockGHActionsService, c *MockPublicKeyCache) {
+				c.EXPECT().Get(gomock.Any(), "repos/aereal/myrepo").Return(staleKey, nil).Times(1)
+				rejected := m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &encryptedSecretMatcher{name: "MY_SECRET", keyID: "0xstale"}).
+					Return(&github.Response{Response: unprocessable}, &github.ErrorResponse{Response: unprocessable}).
+					Times(1)
+				invalidated := c.EXPECT().Invalidate(gomock.Any(), "repos/aereal/myrepo").Return(nil).Times(1).After(rejected)
+				c.EXPECT().Put(gomock.Any(), "repos/aereal/myrepo", pubKey).Return(nil).Times(1)
+				succeedsCreateOrUpdateRepoSecret(m).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1).After(invalidated))
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionUpdated, KeyID: "0xdeadbeaf", Attempts: 3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockClient := NewMockGHActionsService(ctrl)
+			mockCache := NewMockPublicKeyCache(ctrl)
+			tc.doMock(mockClient, mockCache)
+			got, gotErr := usecases.
+				NewRegisterRepositorySecret(mockClient, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1}), usecases.WithPublicKeyCache(mockCache)).
+				DoRegisterRepositorySecret(t.Context(), "aereal", "myrepo", "MY_SECRET", "blah blah")
+			if gotErr != nil {
+				t.Fatal(gotErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("result (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var errFailed = errors.New("failure")
@@ -0,0 +1,150 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/log"
+	"github.com/google/go-github/v69/github"
+)
+
+// RetryPolicy controls how idempotent GitHub API calls are retried on transient failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// do calls fn until it succeeds, fails with a non-transient error or the attempts are exhausted.
+// It returns the number of attempts made, each of which is told to metrics.
+func (p RetryPolicy) do(ctx context.Context, logger *slog.Logger, metrics Metrics, op string, fn func() (*github.Response, error)) (int, error) {
+	maxAttempts := max(p.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		startedAt := time.Now()
+		resp, err := fn()
+		status := 0
+		if resp != nil && resp.Response != nil {
+			status = resp.StatusCode
+		}
+		metrics.ObserveAPICall(op, status, time.Since(startedAt))
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil {
+			return attempt, err
+		}
+		delay, ok := p.delay(attempt, err)
+		if !ok {
+			return attempt, err
+		}
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Until(deadline) < delay {
+			// retrying earlier than told would only hit the rate limit again
+			logger.WarnContext(ctx, "giving up GitHub API call as the retry delay exceeds the deadline",
+				slog.String("api.operation", op),
+				slog.Int("api.attempt", attempt),
+				slog.Duration("api.retry_delay", delay),
+				log.AttrError(err),
+			)
+			return attempt, err
+		}
+		logger.WarnContext(ctx, "retrying GitHub API call",
+			slog.String("api.operation", op),
+			slog.Int("api.attempt", attempt),
+			slog.Duration("api.retry_delay", delay),
+			log.AttrError(err),
+		)
+		metrics.CountRetry(op)
+		if isRateLimitError(err) {
+			metrics.ObserveRateLimitWait(op, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt, or false if err is not worth retrying.
+// The wait told by a rate limit is respected in full, beyond MaxDelay.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if errors.Is(err, context.Canceled) {
+		return 0, false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the run itself is still alive (checked by the caller), so only this request timed out
+		return p.backoff(attempt), true
+	}
+	if abuseErr := new(github.AbuseRateLimitError); errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return p.backoff(attempt), true
+	}
+	if rateErr := new(github.RateLimitError); errors.As(err, &rateErr) {
+		if rateErr.Rate.Reset.IsZero() {
+			return p.backoff(attempt), true
+		}
+		return max(time.Until(rateErr.Rate.Reset.Time), 0), true
+	}
+	if errResp := new(github.ErrorResponse); errors.As(err, &errResp) {
+		if errResp.Response == nil || !isTransientStatus(errResp.Response.StatusCode) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+	if netErr := net.Error(nil); errors.As(err, &netErr) {
+		return p.backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff returns the exponential backoff for the attempt with full jitter applied.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) //nolint:gosec // jitter does not need a cryptographically secure source
+}
+
+// isRateLimitError tells whether the retried err is due to the primary or secondary rate limit.
+func isRateLimitError(err error) bool {
+	if abuseErr := new(github.AbuseRateLimitError); errors.As(err, &abuseErr) {
+		return true
+	}
+	if rateErr := new(github.RateLimitError); errors.As(err, &rateErr) {
+		return true
+	}
+	errResp := new(github.ErrorResponse)
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusTooManyRequests
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,169 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRegisterRepositorySecret_Do_metrics(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := gomock.NewController(t)
+	mockClient := NewMockGHActionsService(ctrl)
+	succeedsCreateOrUpdateRepoSecret(mockClient).
+		Times(1).
+		After(
+			mockClient.EXPECT().
+				GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").
+				Return(pubKey, &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}, nil).
+				Times(1).
+				After(failsGetRepoPublicKeyWithStatus(mockClient, http.StatusTooManyRequests).Times(1).
+					After(failsGetRepoPublicKeyWithStatus(mockClient, http.StatusBadGateway).Times(1))),
+		)
+	m := new(recordingMetrics)
+	_, gotErr := usecases.
+		NewRegisterRepositorySecret(mockClient, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 3}), usecases.WithMetrics(m)).
+		DoRegisterRepositorySecret(t.Context(), "aereal", "myrepo", "MY_SECRET", "blah blah")
+	if gotErr != nil {
+		t.Fatal(gotErr)
+	}
+	want := recordedMetrics{
+		calls: []apiCall{
+			{operation: "GetRepoPublicKey", status: http.StatusBadGateway},
+			{operation: "GetRepoPublicKey", status: http.StatusTooManyRequests},
+			{operation: "GetRepoPublicKey", status: http.StatusOK},
+			{operation: "CreateOrUpdateRepoSecret"},
+		},
+		retries:        []string{"GetRepoPublicKey", "GetRepoPublicKey"},
+		rateLimitWaits: []string{"GetRepoPublicKey"},
+	}
+	if diff := cmp.Diff(want, m.recorded, cmp.AllowUnexported(recordedMetrics{}, apiCall{})); diff != "" {
+		t.Errorf("metrics (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRegisterRepositorySecret_Do_rateLimit(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forbidden := &http.Response{StatusCode: http.StatusForbidden}
+	testCases := []struct {
+		rateLimitErr func() error
+		name         string
+		timeout      time.Duration
+		minElapsed   time.Duration
+		wantErr      bool
+	}{
+		{
+			name: "secondary rate limit waits for Retry-After beyond MaxDelay",
+			rateLimitErr: func() error {
+				return &github.AbuseRateLimitError{Response: forbidden, RetryAfter: github.Ptr(100 * time.Millisecond)}
+			},
+			minElapsed: 100 * time.Millisecond,
+		},
+		{
+			name: "primary rate limit waits until the reset",
+			rateLimitErr: func() error {
+				return &github.RateLimitError{Response: forbidden, Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(100 * time.Millisecond)}}}
+			},
+			minElapsed: 50 * time.Millisecond,
+		},
+		{
+			name: "Retry-After beyond the deadline",
+			rateLimitErr: func() error {
+				return &github.AbuseRateLimitError{Response: forbidden, RetryAfter: github.Ptr(time.Hour)}
+			},
+			timeout: time.Minute,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockClient := NewMockGHActionsService(ctrl)
+			limited := mockClient.EXPECT().
+				GetRepoPublicKey(gomock.Any(), "aereal", "myrepo").
+				DoAndReturn(func(context.Context, string, string) (*github.PublicKey, *github.Response, error) {
+					return nil, &github.Response{Response: forbidden}, tc.rateLimitErr()
+				}).
+				Times(1)
+			if !tc.wantErr {
+				succeedsCreateOrUpdateRepoSecret(mockClient).Times(1).After(succeedsGetRepoPublicKey(mockClient, pubKey).Times(1).After(limited))
+			}
+			ctx := t.Context()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			startedAt := time.Now()
+			_, gotErr := usecases.
+				NewRegisterRepositorySecret(mockClient, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 2, MaxDelay: time.Millisecond})).
+				DoRegisterRepositorySecret(ctx, "aereal", "myrepo", "MY_SECRET", "blah blah")
+			elapsed := time.Since(startedAt)
+			if tc.wantErr {
+				if abuseErr := new(github.AbuseRateLimitError); !errors.As(gotErr, &abuseErr) {
+					t.Errorf("want the rate limit error but got %v", gotErr)
+				}
+				if elapsed >= tc.timeout {
+					t.Errorf("want to give up at once but took %s", elapsed)
+				}
+				return
+			}
+			if gotErr != nil {
+				t.Fatal(gotErr)
+			}
+			if elapsed < tc.minElapsed {
+				t.Errorf("want to wait at least %s but retried after %s", tc.minElapsed, elapsed)
+			}
+		})
+	}
+}
+
+type apiCall struct {
+	operation string
+	status    int
+}
+
+type recordedMetrics struct {
+	calls          []apiCall
+	retries        []string
+	rateLimitWaits []string
+}
+
+type recordingMetrics struct {
+	recorded recordedMetrics
+	mux      sync.Mutex
+}
+
+var _ usecases.Metrics = (*recordingMetrics)(nil)
+
+func (m *recordingMetrics) ObserveAPICall(operation string, status int, _ time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.recorded.calls = append(m.recorded.calls, apiCall{operation: operation, status: status})
+}
+
+func (m *recordingMetrics) CountRetry(operation string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.recorded.retries = append(m.recorded.retries, operation)
+}
+
+func (m *recordingMetrics) ObserveRateLimitWait(operation string, _ time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.recorded.rateLimitWaits = append(m.recorded.rateLimitWaits, operation)
+}
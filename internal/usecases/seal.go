@@ -0,0 +1,108 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// SealedSecret is a secret value encrypted with the public key of its target; only GitHub can decrypt it.
+type SealedSecret struct {
+	Name           string
+	KeyID          string
+	EncryptedValue string
+}
+
+func (s *SealedSecret) encryptedSecret() *github.EncryptedSecret {
+	return &github.EncryptedSecret{Name: s.Name, KeyID: s.KeyID, EncryptedValue: s.EncryptedValue}
+}
+
+// SealSecret encrypts the plain value with the public key without talking to GitHub.
+func SealSecret(key *github.PublicKey, secretName, plainMsg string) (*SealedSecret, error) {
+	serverPubKey, err := getRawPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptAndEncode([]byte(plainMsg), serverPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SealedSecret{Name: secretName, KeyID: key.GetKeyID(), EncryptedValue: encrypted}, nil
+}
+
+// KeyMismatchError tells that the secret was sealed with a public key that is no longer the current one of the repository.
+type KeyMismatchError struct {
+	Owner        string
+	Repo         string
+	SealedKeyID  string
+	CurrentKeyID string
+}
+
+func (e *KeyMismatchError) Error() string {
+	return fmt.Sprintf("the secret for %s/%s was sealed with the key %s but the current key is %s; seal it again", e.Owner, e.Repo, e.SealedKeyID, e.CurrentKeyID)
+}
+
+func (e *KeyMismatchError) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("owner", e.Owner), slog.String("repo", e.Repo), slog.String("sealed_key_id", e.SealedKeyID), slog.String("current_key_id", e.CurrentKeyID))
+}
+
+func NewGetRepositoryPublicKey(client GHActionsService, opts ...Option) *GetRepositoryPublicKey {
+	return &GetRepositoryPublicKey{client: client, options: newOptions(opts)}
+}
+
+// GetRepositoryPublicKey fetches the current public key of a repository, bypassing any cache.
+type GetRepositoryPublicKey struct {
+	client GHActionsService
+	options
+}
+
+func (u *GetRepositoryPublicKey) DoGetRepositoryPublicKey(ctx context.Context, repoOwner string, repoName string) (*github.PublicKey, error) {
+	logger := slog.Default().With(
+		slog.String("repo.owner", repoOwner),
+		slog.String("repo.name", repoName),
+	)
+	key, _, err := u.getRepoPublicKey(ctx, logger, u.client, repoOwner, repoName)
+	return key, err
+}
+
+func NewUploadSealedRepositorySecret(client GHActionsService, opts ...Option) *UploadSealedRepositorySecret {
+	return &UploadSealedRepositorySecret{client: client, options: newOptions(opts)}
+}
+
+// UploadSealedRepositorySecret writes a secret sealed beforehand, refusing it if the key it was sealed with has been rotated.
+type UploadSealedRepositorySecret struct {
+	client GHActionsService
+	options
+}
+
+func (u *UploadSealedRepositorySecret) DoUploadSealedRepositorySecret(ctx context.Context, repoOwner string, repoName string, sealed *SealedSecret) (*RegisterResult, error) {
+	logger := slog.Default().With(
+		slog.String("repo.owner", repoOwner),
+		slog.String("repo.name", repoName),
+		slog.String("secret.name", sealed.Name),
+	)
+	current, getKeyAttempts, err := u.getRepoPublicKey(ctx, logger, u.client, repoOwner, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if current.GetKeyID() != sealed.KeyID {
+		return nil, &KeyMismatchError{Owner: repoOwner, Repo: repoName, SealedKeyID: sealed.KeyID, CurrentKeyID: current.GetKeyID()}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("not written: %w", ctxErr)
+	}
+	logger.InfoContext(ctx, "upload sealed repository secret", slog.String("key.id", sealed.KeyID))
+	createResp, createAttempts, err := u.putRepoSecret(ctx, logger, u.client, repoOwner, repoName, sealed.encryptedSecret())
+	if err != nil {
+		return nil, fmt.Errorf("CreateOrUpdateRepoSecret (%d attempts): %w", createAttempts, err)
+	}
+	result := &RegisterResult{
+		Action:   actionFromResponse(createResp),
+		KeyID:    sealed.KeyID,
+		Attempts: getKeyAttempts + createAttempts,
+	}
+	logger.InfoContext(ctx, "repository secret set", slog.String("secret.action", string(result.Action)))
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package usecases_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/assertions"
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestSealSecret(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &github.PublicKey{KeyID: ref("0xdeadbeaf"), Key: ref(base64.StdEncoding.EncodeToString(pub[:]))}
+	sealed, err := usecases.SealSecret(key, "MY_SECRET", "blah blah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Name != "MY_SECRET" || sealed.KeyID != "0xdeadbeaf" {
+		t.Errorf("unexpected sealed secret: %#v", sealed)
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(sealed.EncryptedValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, ok := box.OpenAnonymous(nil, encrypted, pub, priv)
+	if !ok {
+		t.Fatal("failed to open the sealed value")
+	}
+	if string(opened) != "blah blah" {
+		t.Errorf("opened value = %q", opened)
+	}
+}
+
+func TestUploadSealedRepositorySecret_Do(t *testing.T) {
+	pubKey, err := getPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		wantErr error
+		doMock  func(m *MockGHActionsService)
+		want    *usecases.RegisterResult
+		name    string
+		keyID   string
+	}{
+		{
+			name:  "ok",
+			keyID: "0xdeadbeaf",
+			doMock: func(m *MockGHActionsService) {
+				m.EXPECT().
+					CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "myrepo", &github.EncryptedSecret{Name: "MY_SECRET", KeyID: "0xdeadbeaf", EncryptedValue: "c2VhbGVk"}).
+					Return(&github.Response{Response: &http.Response{StatusCode: http.StatusCreated}}, nil).
+					Times(1).
+					After(succeedsGetRepoPublicKey(m, pubKey).Times(1))
+			},
+			want: &usecases.RegisterResult{Action: usecases.SecretActionCreated, KeyID: "0xdeadbeaf", Attempts: 2},
+		},
+		{
+			name:  "key rotated since sealed",
+			keyID: "0xold",
+			doMock: func(m *MockGHActionsService) {
+				_ = succeedsGetRepoPublicKey(m, pubKey).Times(1)
+			},
+			wantErr: assertions.LiteralError("the secret for aereal/myrepo was sealed with the key 0xold but the current key is 0xdeadbeaf; seal it again"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockClient := NewMockGHActionsService(ctrl)
+			tc.doMock(mockClient)
+			sealed := &usecases.SealedSecret{Name: "MY_SECRET", KeyID: tc.keyID, EncryptedValue: "c2VhbGVk"}
+			got, gotErr := usecases.
+				NewUploadSealedRepositorySecret(mockClient, usecases.WithRetryPolicy(usecases.RetryPolicy{MaxAttempts: 1})).
+				DoUploadSealedRepositorySecret(t.Context(), "aereal", "myrepo", sealed)
+			if diff := assertions.DiffErrorsConservatively(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("error (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("result (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/go-github/v69/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aereal/register-github-secret/internal/usecases")
+
+// NewTracedActionsService returns the GHActionsService recording a span for each call made through client.
+// The spans take the attribute keys of the logs, such as repo.owner, repo.name and secret.name.
+func NewTracedActionsService(client GHActionsService) GHActionsService {
+	return &tracedActionsService{client: client}
+}
+
+type tracedActionsService struct {
+	client GHActionsService
+}
+
+var _ GHActionsService = (*tracedActionsService)(nil)
+
+func (s *tracedActionsService) GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error) {
+	ctx, span := startAPISpan(ctx, "GetRepoPublicKey", attribute.String("repo.owner", owner), attribute.String("repo.name", repo))
+	key, resp, err := s.client.GetRepoPublicKey(ctx, owner, repo)
+	endAPISpan(span, resp, err)
+	return key, resp, err
+}
+
+func (s *tracedActionsService) CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	ctx, span := startAPISpan(ctx, "CreateOrUpdateRepoSecret",
+		attribute.String("repo.owner", owner),
+		attribute.String("repo.name", repo),
+		attribute.String("secret.name", eSecret.Name),
+		attribute.String("key.id", eSecret.KeyID),
+	)
+	resp, err := s.client.CreateOrUpdateRepoSecret(ctx, owner, repo, eSecret)
+	endAPISpan(span, resp, err)
+	return resp, err
+}
+
+func (s *tracedActionsService) ListRepoSecrets(ctx context.Context, owner, repo string, opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+	ctx, span := startAPISpan(ctx, "ListRepoSecrets", attribute.String("repo.owner", owner), attribute.String("repo.name", repo))
+	secrets, resp, err := s.client.ListRepoSecrets(ctx, owner, repo, opts)
+	endAPISpan(span, resp, err)
+	return secrets, resp, err
+}
+
+func startAPISpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("api.operation", operation))
+	return tracer.Start(ctx, "GHActionsService."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endAPISpan(span trace.Span, resp *github.Response, err error) {
+	defer span.End()
+	if resp != nil && resp.Response != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if requestID := resp.Header.Get("X-GitHub-Request-Id"); requestID != "" {
+			span.SetAttributes(attribute.String("github.request_id", requestID))
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
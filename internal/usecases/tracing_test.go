@@ -0,0 +1,85 @@
+package usecases_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/aereal/register-github-secret/internal/usecases"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/mock/gomock"
+)
+
+// spanRecorder records the spans of the tests; the global tracer provider is bound only once in a test binary.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+func TestNewTracedActionsService(t *testing.T) {
+	recorder := spanRecorder()
+	recorded := len(recorder.Ended())
+
+	ctrl := gomock.NewController(t)
+	actions := NewMockGHActionsService(ctrl)
+	resp := &github.Response{Response: &http.Response{StatusCode: http.StatusCreated, Header: http.Header{"X-Github-Request-Id": {"ABCD:1234"}}}}
+	actions.EXPECT().
+		GetRepoPublicKey(gomock.Any(), "aereal", "repo1").
+		Return(nil, nil, errFailed).
+		Times(1)
+	actions.EXPECT().
+		CreateOrUpdateRepoSecret(gomock.Any(), "aereal", "repo1", &github.EncryptedSecret{Name: "MY_SECRET", KeyID: "0x1"}).
+		Return(resp, nil).
+		Times(1)
+	traced := usecases.NewTracedActionsService(actions)
+	_, _, _ = traced.GetRepoPublicKey(t.Context(), "aereal", "repo1")
+	_, _ = traced.CreateOrUpdateRepoSecret(t.Context(), "aereal", "repo1", &github.EncryptedSecret{Name: "MY_SECRET", KeyID: "0x1"})
+
+	type span struct {
+		Attributes map[attribute.Key]string
+		Name       string
+		Status     codes.Code
+	}
+	want := []span{
+		{
+			Name:   "GHActionsService.GetRepoPublicKey",
+			Status: codes.Error,
+			Attributes: map[attribute.Key]string{
+				"repo.owner":    "aereal",
+				"repo.name":     "repo1",
+				"api.operation": "GetRepoPublicKey",
+			},
+		},
+		{
+			Name:   "GHActionsService.CreateOrUpdateRepoSecret",
+			Status: codes.Unset,
+			Attributes: map[attribute.Key]string{
+				"repo.owner":                "aereal",
+				"repo.name":                 "repo1",
+				"secret.name":               "MY_SECRET",
+				"key.id":                    "0x1",
+				"api.operation":             "CreateOrUpdateRepoSecret",
+				"http.response.status_code": "201",
+				"github.request_id":         "ABCD:1234",
+			},
+		},
+	}
+	var got []span
+	for _, s := range recorder.Ended()[recorded:] {
+		attrs := map[attribute.Key]string{}
+		for _, kv := range s.Attributes() {
+			attrs[kv.Key] = kv.Value.Emit()
+		}
+		got = append(got, span{Name: s.Name(), Status: s.Status().Code, Attributes: attrs})
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("spans (-want, +got):\n%s", diff)
+	}
+}